@@ -0,0 +1,72 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tklauenberg/chart-releaser/pkg/changed"
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// changedCmd represents the changed command
+var changedCmd = &cobra.Command{
+	Use:   "changed",
+	Short: "List charts changed since their latest release",
+	Long: `This command lists the charts in the charts directory which need to be
+packaged, because they have changed since their latest release or have never
+been released.
+
+Charts are searched for in the charts directory and its subdirectories. The
+latest release of a chart is the tag with the highest version matching the
+release name template. The chart directory is compared between that tag and
+HEAD, so make sure tags have been fetched, e.g. with 'git fetch --tags'.
+
+By default, the paths of the changed charts are printed one per line, so they
+can be passed to 'cr package'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := config.LoadConfiguration(cfgFile, cmd, getRequiredChangedArgs())
+		if err != nil {
+			return err
+		}
+		ctx, cancel := commandContext(cmd, config)
+		defer cancel()
+
+		detector := changed.NewDetector(config, newGit(config))
+		charts, err := detector.ChangedCharts(ctx)
+		if err != nil {
+			return err
+		}
+
+		var paths []string
+		for _, ch := range charts {
+			paths = append(paths, ch.Path)
+		}
+		return printCharts(cmd, config, charts, paths)
+	},
+}
+
+func getRequiredChangedArgs() []string {
+	return []string{"charts-dir"}
+}
+
+func init() {
+	rootCmd.AddCommand(changedCmd)
+	changedCmd.Flags().String("charts-dir", "charts", "Path to directory which is searched for charts")
+	changedCmd.Flags().StringSlice("exclude", []string{}, "Glob patterns of chart paths relative to --charts-dir to skip, e.g. 'incubator/*'")
+	changedCmd.Flags().Bool("skip-library-charts", false, "Skip library charts")
+	changedCmd.Flags().String("release-name-template", "{{ .Name }}-{{ .Version }}", "Go template for computing release names, using chart metadata")
+	changedCmd.Flags().StringP("output", "o", "text", "Output format, either 'text' or 'json'")
+	changedCmd.Flags().String("git-backend", "exec", "Git implementation to use: 'exec' runs the git binary, 'go-git' needs no git binary")
+}
@@ -0,0 +1,107 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/packager"
+)
+
+// checkAPIsCmd represents the check-apis command
+var checkAPIsCmd = &cobra.Command{
+	Use:   "check-apis [CHART_PATH] [...]",
+	Short: "Check charts for deprecated and removed Kubernetes APIs",
+	Long: `This command renders charts client-side with their default values and every
+ci/*-values.yaml file, and checks the apiVersion and kind of every manifest
+against a bundled table of Kubernetes API deprecations and removals.
+
+APIs are checked for the Kubernetes version given with --kube-version, else for
+the versions allowed by the kubeVersion in Chart.yaml, else for all versions.
+Removed APIs are errors and deprecated APIs warnings by default; the command
+fails if there are errors.
+
+With --charts-dir, the directory tree is searched for charts instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := config.LoadConfiguration(cfgFile, cmd, nil)
+		if err != nil {
+			return err
+		}
+
+		if config.ChartsDir != "" {
+			if len(args) > 0 {
+				return errors.New("specify either chart paths or --charts-dir, but not both")
+			}
+			charts, err := packager.DiscoverCharts(config)
+			if err != nil {
+				return err
+			}
+			for _, ch := range charts {
+				args = append(args, ch.Path)
+			}
+		} else if len(args) == 0 {
+			args = append(args, ".")
+		}
+
+		findings := []*packager.APIFinding{}
+		for _, chartPath := range args {
+			chartFindings, err := packager.CheckAPIs(config, chartPath)
+			if err != nil {
+				return err
+			}
+			findings = append(findings, chartFindings...)
+		}
+
+		if config.Output == "json" {
+			out, err := json.MarshalIndent(findings, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		} else {
+			for _, finding := range findings {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", finding.Chart, finding)
+			}
+		}
+
+		errorCount := 0
+		for _, finding := range findings {
+			if finding.Level == packager.LevelError {
+				errorCount++
+			}
+		}
+		if errorCount > 0 {
+			// Findings are not a usage error.
+			cmd.SilenceUsage = true
+			return errors.Errorf("found %d removed or deprecated Kubernetes APIs", errorCount)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkAPIsCmd)
+	checkAPIsCmd.Flags().String("charts-dir", "", "Path to directory which is searched for charts to check, instead of giving chart paths")
+	checkAPIsCmd.Flags().StringSlice("exclude", []string{}, "Glob patterns of chart paths relative to --charts-dir to skip, e.g. 'incubator/*'")
+	checkAPIsCmd.Flags().Bool("skip-library-charts", false, "Skip library charts found in --charts-dir")
+	checkAPIsCmd.Flags().String("kube-version", "", "Kubernetes version to check APIs for (default the chart's kubeVersion, or all versions)")
+	checkAPIsCmd.Flags().String("deprecated-apis", "warning", "Level of deprecated Kubernetes APIs: 'off', 'warning' or 'error'")
+	checkAPIsCmd.Flags().String("removed-apis", "error", "Level of removed Kubernetes APIs: 'off', 'warning' or 'error'")
+	checkAPIsCmd.Flags().StringP("output", "o", "text", "Output format, either 'text' or 'json'")
+}
@@ -20,7 +20,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/tklauenberg/chart-releaser/pkg/config"
-	"github.com/tklauenberg/chart-releaser/pkg/git"
 	"github.com/tklauenberg/chart-releaser/pkg/github"
 	"github.com/tklauenberg/chart-releaser/pkg/releaser"
 )
@@ -38,6 +37,9 @@ given GitHub repository's releases.
 		if err != nil {
 			return err
 		}
+		if err := checkCommitSigning(config); err != nil {
+			return err
+		}
 
 		if len(config.ChartsRepo) > 0 {
 			fmt.Fprintf(os.Stderr, "ATTENTION: Flag --charts-repo is deprecated. It does not have any effect.\n"+
@@ -47,9 +49,12 @@ given GitHub repository's releases.
 				"The flag will be removed with the next major release.", config.PagesBranch)
 		}
 
+		ctx, cancel := commandContext(cmd, config)
+		defer cancel()
+
 		ghc := github.NewClient(config.Owner, config.GitRepo, config.Token, config.GitBaseURL, config.GitUploadURL)
-		releaser := releaser.NewReleaser(config, ghc, &git.Git{})
-		_, err = releaser.UpdateIndexFile()
+		releaser := releaser.NewReleaser(config, ghc, newGit(config))
+		_, err = releaser.UpdateIndexFile(ctx)
 		return err
 	},
 }
@@ -75,5 +80,18 @@ func init() {
 	flags.String("remote", "origin", "The Git remote used when creating a local worktree for the GitHub Pages branch")
 	flags.Bool("push", false, "Push index.yaml to the GitHub Pages branch (must not be set if --pr is set)")
 	flags.Bool("pr", false, "Create a pull request for index.yaml against the GitHub Pages branch (must not be set if --push is set)")
+	flags.String("pr-branch", "chart-releaser-index", "Branch the --pr pull request is opened from. An open pull request from this branch is updated instead of opening a new one")
+	flags.StringSlice("pr-labels", nil, "Labels to add to the --pr pull request")
+	flags.StringSlice("pr-reviewers", nil, "Users to request a review of the --pr pull request from")
+	flags.StringSlice("pr-team-reviewers", nil, "Teams to request a review of the --pr pull request from")
+	flags.Bool("pr-auto-merge", false, "Enable auto-merge for the --pr pull request")
+	flags.String("pr-merge-method", "merge", "Merge method used by --pr-auto-merge: 'merge', 'squash' or 'rebase'")
 	flags.String("release-name-template", "{{ .Name }}-{{ .Version }}", "Go template for computing release names, using chart metadata")
+	flags.String("git-backend", "exec", "Git implementation to use: 'exec' runs the git binary, 'go-git' needs no git binary")
+	flags.String("committer-name", "", "Name used for the index commit (default from the Git configuration)")
+	flags.String("committer-email", "", "Email used for the index commit (default from the Git configuration)")
+	flags.Bool("sign-commits", false, "Sign the index commit")
+	flags.String("commit-signing-key", "", "Key to sign the index commit with: a GPG key ID, or the path to an armored private key with --git-backend go-git, or the path to an SSH key")
+	flags.String("commit-signing-format", "gpg", "Format of the commit signature: 'gpg' or 'ssh'")
+	flags.String("commit-message-template", "", "Go template for the index commit message. It has access to .IndexPath and .Charts, the metadata of the chart versions added to the index")
 }
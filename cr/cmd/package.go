@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/tklauenberg/chart-releaser/pkg/config"
 	"github.com/tklauenberg/chart-releaser/pkg/packager"
@@ -28,21 +29,69 @@ var packageCmd = &cobra.Command{
 is given, this will look at that path for a chart (which must contain a
 Chart.yaml file) and then package that directory.
 
+With --charts-dir, the directory tree is searched for charts instead and all
+charts found are packaged. Use --list to only print the charts found, e.g. for
+use by other tooling.
+
+The chart version and appVersion can be overridden with --version and
+--app-version, e.g. for nightly builds:
+
+  cr package --version '{{ .Version }}-dev.{{ .Git.CommitsSinceTag }}+{{ .Git.ShortCommit }}'
+
+Charts which depend on other charts being packaged through a file:// repository
+are packaged after them.
+
+With --reproducible, packaging the same chart source always gives the same
+archive. All files get the time from SOURCE_DATE_EPOCH, or else of the last
+commit.
+
+With --sbom, every chart is rendered client-side with its default values and
+the values files matching --sbom-values. The container images referenced in the
+manifests are listed in an SBOM written next to the package, and in the
+artifacthub.io/images annotation of the chart unless it already has one.
+
+With --manifest, the path, chart name, version, appVersion, digest, signature
+status and source directory of every package are written to a manifest, which
+'cr upload --manifest' can read instead of searching the package path.
+
+Every package is scanned for likely secrets such as private keys and tokens,
+environment files, Git directories and oversized files, and removed if any are
+found which are not on the scan allowlist in the config file.
 
 If you wish to use advanced packaging options such as creating signed
 packages or updating chart dependencies please use "helm package" instead.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var err error
-		if len(args) == 0 {
-			args = append(args, ".")
-		}
 		config, err := config.LoadConfiguration(cfgFile, cmd, getRequiredPackageArgs())
 		if err != nil {
 			return err
 		}
 
-		p := packager.NewPackager(config, args)
-		return p.CreatePackages()
+		if config.ChartsDir != "" {
+			if len(args) > 0 {
+				return errors.New("specify either chart paths or --charts-dir, but not both")
+			}
+			charts, err := packager.DiscoverCharts(config)
+			if err != nil {
+				return err
+			}
+			for _, ch := range charts {
+				args = append(args, ch.Path)
+			}
+			if config.List {
+				return printCharts(cmd, config, charts, args)
+			}
+		} else if config.List {
+			return errors.New("--list requires --charts-dir")
+		} else if len(args) == 0 {
+			args = append(args, ".")
+		}
+
+		ctx, cancel := commandContext(cmd, config)
+		defer cancel()
+
+		p := packager.NewPackager(config, args, newGit(config))
+		return p.CreatePackages(ctx)
 
 	},
 }
@@ -55,7 +104,32 @@ func init() {
 	rootCmd.AddCommand(packageCmd)
 	packageCmd.Flags().StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages")
 	packageCmd.Flags().Bool("sign", false, "Use a PGP private key to sign this package")
-	packageCmd.Flags().String("key", "", "Name of the key to use when signing")
-	packageCmd.Flags().String("keyring", "~/.gnupg/pubring.gpg", "Location of a public keyring")
+	packageCmd.Flags().String("key", "", "Name of the key to use when signing. May be omitted if the keyring contains a single private key")
+	packageCmd.Flags().String("keyring", "~/.gnupg/pubring.gpg", "Location of a keyring with the private signing key, armored or binary. Use '-' in order to read from stdin")
+	packageCmd.Flags().String("signing-key-env", "", "Name of an environment variable holding the armored private signing key, instead of --keyring")
+	packageCmd.Flags().String("charts-dir", "", "Path to directory which is searched for charts to package, instead of giving chart paths")
+	packageCmd.Flags().StringSlice("exclude", []string{}, "Glob patterns of chart paths relative to --charts-dir to skip, e.g. 'incubator/*'")
+	packageCmd.Flags().Bool("skip-library-charts", false, "Skip library charts found in --charts-dir")
+	packageCmd.Flags().Bool("list", false, "Print the charts found in --charts-dir instead of packaging them")
+	packageCmd.Flags().StringP("output", "o", "text", "Output format of --list, either 'text' or 'json'")
+	packageCmd.Flags().Int("concurrency", 1, "Number of charts to package in parallel. Charts are still packaged after their file:// dependencies")
+	packageCmd.Flags().Bool("reproducible", false, "Create byte-identical packages for the same chart source, with sorted files and timestamps from SOURCE_DATE_EPOCH or the last commit")
+	packageCmd.Flags().String("sbom", "", "Write an SBOM with the container images of each chart next to its package, either 'cyclonedx' or 'spdx'")
+	packageCmd.Flags().StringSlice("sbom-values", []string{}, "Glob patterns of values files relative to the chart directory to also render the chart with for --sbom, e.g. 'ci/*-values.yaml'")
+	packageCmd.Flags().Bool("lint", false, "Lint each chart with its default values and every ci/*-values.yaml file before packaging it, and stop on errors")
+	packageCmd.Flags().Bool("lint-strict", false, "Like --lint, but also stop on warnings")
+	packageCmd.Flags().Bool("render-test", false, "Render each chart offline with its default values and every ci/*-values.yaml file before packaging it, and stop if it fails to render or renders invalid YAML")
+	packageCmd.Flags().Bool("check-apis", false, "Render each chart and check it for deprecated and removed Kubernetes APIs before packaging it")
+	packageCmd.Flags().String("kube-version", "", "Kubernetes version to check APIs for with --check-apis (default the chart's kubeVersion, or all versions)")
+	packageCmd.Flags().String("deprecated-apis", "warning", "Level of deprecated Kubernetes APIs found by --check-apis: 'off', 'warning' or 'error'")
+	packageCmd.Flags().String("removed-apis", "error", "Level of removed Kubernetes APIs found by --check-apis: 'off', 'warning' or 'error'")
+	packageCmd.Flags().String("manifest", "", "Write a manifest of the created packages to this path, as YAML if it ends in .yaml or .yml and as JSON otherwise")
+	packageCmd.Flags().String("version", "", "Set the version of the packaged charts. Go template with the chart's .Name, .Version and .AppVersion, "+
+		"and Git metadata in .Git: .Commit, .ShortCommit, .Branch, .Tag, .CommitsSinceTag, .CommitTime and .Describe")
+	packageCmd.Flags().String("app-version", "", "Set the appVersion of the packaged charts. Go template with the same data as --version")
+	packageCmd.Flags().Bool("skip-dependency-update", false, "Package the dependencies vendored in charts/ without downloading them, after verifying them against Chart.lock")
+	packageCmd.Flags().Bool("offline", false, "Alias for --skip-dependency-update, for builders without network access")
+	packageCmd.Flags().String("git-backend", "exec", "Git implementation to use: 'exec' runs the git binary, 'go-git' needs no git binary")
 	packageCmd.Flags().String("passphrase-file", "", "Location of a file which contains the passphrase for the signing key. Use '-' in order to read from stdin")
+	packageCmd.Flags().String("passphrase-env", "", "Name of an environment variable holding the passphrase for the signing key, instead of --passphrase-file")
 }
@@ -15,9 +15,19 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/tklauenberg/chart-releaser/pkg/changed"
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/git"
+	"github.com/tklauenberg/chart-releaser/pkg/packager"
+	"github.com/tklauenberg/chart-releaser/pkg/releaser"
 )
 
 var cfgFile string
@@ -32,11 +42,78 @@ and Chart metadata to GitHub Releases and creating a suitable index file
 `}
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel running operations on SIGINT/SIGTERM so that partially downloaded
+	// files and temporary worktrees get cleaned up.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// commandContext returns the context for running a command, bounded by the
+// configured timeout if there is one.
+func commandContext(cmd *cobra.Command, opts *config.Options) (context.Context, context.CancelFunc) {
+	if opts.Timeout > 0 {
+		return context.WithTimeout(cmd.Context(), opts.Timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
+// printCharts writes charts to the command's output, as JSON or as their paths
+// one per line, depending on the configured output format.
+func printCharts(cmd *cobra.Command, opts *config.Options, charts interface{}, paths []string) error {
+	if opts.Output == "json" {
+		out, err := json.MarshalIndent(charts, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		return nil
+	}
+	for _, path := range paths {
+		fmt.Fprintln(cmd.OutOrStdout(), path)
+	}
+	return nil
+}
+
+// gitClient is implemented by all Git backends.
+type gitClient interface {
+	releaser.Git
+	changed.Git
+	packager.Git
+}
+
+// newGit returns the Git implementation selected with --git-backend.
+func newGit(opts *config.Options) gitClient {
+	committer := git.Identity{Name: opts.CommitterName, Email: opts.CommitterEmail}
+	var signing *git.Signing
+	if opts.SignCommits {
+		signing = &git.Signing{Format: opts.CommitSigningFormat, Key: opts.CommitSigningKey}
+	}
+
+	if opts.GitBackend == "go-git" {
+		return &git.GoGit{Token: opts.Token, Committer: committer, Signing: signing}
+	}
+	return &git.Git{Token: opts.Token, Committer: committer, Signing: signing}
+}
+
+// checkCommitSigning checks that the selected Git backend can sign commits as
+// configured, before any work is done. Only commands which commit call it, so
+// that other commands do not need the signing key.
+func checkCommitSigning(opts *config.Options) error {
+	if opts.GitBackend != "go-git" || !opts.SignCommits {
+		return nil
+	}
+	signing := &git.Signing{Format: opts.CommitSigningFormat, Key: opts.CommitSigningKey}
+	if err := signing.ValidateGoGit(); err != nil {
+		return fmt.Errorf("invalid commit signing configuration: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default is $HOME/.cr.yaml)")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Maximum duration of the whole operation, e.g. '10m' (default no timeout)")
 }
@@ -17,7 +17,6 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 	"github.com/tklauenberg/chart-releaser/pkg/config"
-	"github.com/tklauenberg/chart-releaser/pkg/git"
 	"github.com/tklauenberg/chart-releaser/pkg/github"
 	"github.com/tklauenberg/chart-releaser/pkg/releaser"
 )
@@ -26,15 +25,33 @@ import (
 var uploadCmd = &cobra.Command{
 	Use:   "upload",
 	Short: "Upload Helm chart packages to GitHub Releases",
-	Long:  `Upload Helm chart packages to GitHub Releases`,
+	Long: `Upload Helm chart packages to GitHub Releases.
+
+All packages are scanned for likely secrets such as private keys and tokens,
+environment files, Git directories and oversized files before anything is
+uploaded, unless they are on the scan allowlist in the config file.
+
+Chart versions must be strict semantic versions greater than the latest version
+of the chart attached to a GitHub release or listed in the index at --index-url.
+Versions are never released twice. With --allow-backport, a version need only
+be greater than the latest on its major.minor line, e.g. 1.2.5 after 1.3.0 if
+1.2.4 is the latest 1.2.x version.
+
+With --check-values, the previous released version of each chart is downloaded
+and its values.yaml and values.schema.json are compared with the new ones.
+Removed keys and schema changes which reject previously valid values are
+breaking, and need a major version bump, or a minor one below 1.0.0.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		config, err := config.LoadConfiguration(cfgFile, cmd, getRequiredUploadArgs())
 		if err != nil {
 			return err
 		}
+		ctx, cancel := commandContext(cmd, config)
+		defer cancel()
+
 		ghc := github.NewClient(config.Owner, config.GitRepo, config.Token, config.GitBaseURL, config.GitUploadURL)
-		releaser := releaser.NewReleaser(config, ghc, &git.Git{})
-		return releaser.CreateReleases()
+		releaser := releaser.NewReleaser(config, ghc, newGit(config))
+		return releaser.CreateReleases(ctx)
 	},
 }
 
@@ -57,4 +74,8 @@ func init() {
 		"If it is set to empty string, or the file is not found, the chart description will be used instead. The file is read from the chart package")
 	uploadCmd.Flags().Bool("generate-release-notes", false, "Whether to automatically generate the name and body for this release. See https://docs.github.com/en/rest/releases/releases")
 	uploadCmd.Flags().Bool("make-release-latest", true, "Mark the created GitHub release as 'latest'")
+	uploadCmd.Flags().Bool("allow-backport", false, "Allow versions lower than the latest released version of a chart, if they are greater than the latest on their major.minor line")
+	uploadCmd.Flags().Bool("check-values", false, "Compare the values.yaml and values.schema.json of each chart with its previous released version, and fail on breaking changes without a major version bump")
+	uploadCmd.Flags().String("index-url", "", "URL of the chart repository index.yaml. Versions in it are never released again, in addition to those attached to GitHub releases")
+	uploadCmd.Flags().String("manifest", "", "Upload the packages listed in this manifest written by 'cr package --manifest', instead of all packages in --package-path")
 }
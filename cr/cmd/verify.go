@@ -0,0 +1,94 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/verifier"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [PACKAGE...]",
+	Short: "Verify chart packages against their provenance files",
+	Long: `This command verifies that chart packages match their signed provenance
+(.prov) files, using the public keys in the keyring.
+
+By default, the given packages or all packages in the package path are
+verified. Their provenance files are expected next to them.
+
+With --index-url, the charts listed in a remote index are verified instead.
+Every version of the given charts, or of all charts, is downloaded together
+with its provenance file, and also checked against the digest in the index.
+
+A report with one line per chart is printed. The command fails if any chart
+could not be verified.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := config.LoadConfiguration(cfgFile, cmd, getRequiredVerifyArgs())
+		if err != nil {
+			return err
+		}
+		ctx, cancel := commandContext(cmd, config)
+		defer cancel()
+
+		v, err := verifier.NewVerifier(config)
+		if err != nil {
+			return err
+		}
+		var results []*verifier.Result
+		if config.IndexURL != "" {
+			results, err = v.VerifyIndex(ctx, args)
+		} else {
+			results, err = v.VerifyPackages(args)
+		}
+		if err != nil {
+			return err
+		}
+
+		if config.Output == "json" {
+			out, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(out))
+		} else {
+			verifier.PrintReport(cmd.OutOrStdout(), results)
+		}
+
+		if failed := verifier.Failed(results); failed > 0 {
+			// A failed verification is not a usage error.
+			cmd.SilenceUsage = true
+			return errors.Errorf("verification failed for %d of %d charts", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func getRequiredVerifyArgs() []string {
+	return []string{"package-path"}
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages")
+	verifyCmd.Flags().String("keyring", "~/.gnupg/pubring.gpg", "Location of a keyring with the public keys to verify against, armored or binary")
+	verifyCmd.Flags().String("index-url", "", "URL of a chart repository index.yaml. The charts listed in it are verified instead of local packages, optionally only those named as arguments")
+	verifyCmd.Flags().StringP("output", "o", "text", "Output format, either 'text' or 'json'")
+}
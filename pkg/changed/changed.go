@@ -0,0 +1,147 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changed
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/packager"
+	"github.com/tklauenberg/chart-releaser/pkg/releaser"
+)
+
+// versionPlaceholder stands in for the chart version when rendering the
+// release name template, to find the tags of all releases of a chart.
+const versionPlaceholder = "0.0.0-cr-version-placeholder"
+
+// Git is the interface for the Git operations needed to detect changes.
+type Git interface {
+	Tags(ctx context.Context, workingDir string) ([]string, error)
+	ChangedFiles(ctx context.Context, workingDir string, rev string, path string) ([]string, error)
+}
+
+// Chart is a chart that changed since its latest release.
+type Chart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	// Tag is the tag of the latest release the chart was compared to. It is
+	// empty if the chart has never been released.
+	Tag string `json:"tag,omitempty"`
+	// Files are the changed files relative to the repository root.
+	Files []string `json:"files,omitempty"`
+}
+
+// Detector finds the charts that need to be packaged.
+type Detector struct {
+	config *config.Options
+	git    Git
+}
+
+// NewDetector returns a configured Detector
+func NewDetector(config *config.Options, git Git) *Detector {
+	return &Detector{
+		config: config,
+		git:    git,
+	}
+}
+
+// ChangedCharts returns the charts found in the charts directory which have
+// changed since their latest release or have never been released. A chart's
+// releases are found by matching the repository's tags against the release
+// name template.
+func (d *Detector) ChangedCharts(ctx context.Context) ([]*Chart, error) {
+	charts, err := packager.DiscoverCharts(d.config)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := d.git.Tags(ctx, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing tags")
+	}
+
+	changed := []*Chart{}
+	for _, discovered := range charts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		tag, err := d.latestTag(discovered.Name, tags)
+		if err != nil {
+			return nil, err
+		}
+
+		ch := &Chart{Name: discovered.Name, Version: discovered.Version, Path: discovered.Path, Tag: tag}
+		if tag == "" {
+			changed = append(changed, ch)
+			continue
+		}
+
+		files, err := d.git.ChangedFiles(ctx, "", tag, ch.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error comparing chart %s with %s", ch.Path, tag)
+		}
+		if len(files) > 0 {
+			ch.Files = files
+			changed = append(changed, ch)
+		}
+	}
+	return changed, nil
+}
+
+// latestTag returns the tag of the release with the highest version of the
+// chart, or an empty string if there is none.
+func (d *Detector) latestTag(name string, tags []string) (string, error) {
+	probe := &chart.Metadata{Name: name, Version: versionPlaceholder}
+	pattern, err := releaser.ReleaseName(d.config.ReleaseNameTemplate, probe)
+	if err != nil {
+		return "", errors.Wrap(err, "error rendering release name template")
+	}
+
+	prefix, suffix, found := strings.Cut(pattern, versionPlaceholder)
+	if !found {
+		// The release name does not depend on the version, so there is at
+		// most one release.
+		for _, tag := range tags {
+			if tag == pattern {
+				return tag, nil
+			}
+		}
+		return "", nil
+	}
+
+	var latestTag string
+	var latest *semver.Version
+	for _, tag := range tags {
+		if len(tag) <= len(prefix)+len(suffix) || !strings.HasPrefix(tag, prefix) || !strings.HasSuffix(tag, suffix) {
+			continue
+		}
+		version, err := semver.StrictNewVersion(tag[len(prefix) : len(tag)-len(suffix)])
+		if err != nil {
+			continue
+		}
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+			latestTag = tag
+		}
+	}
+	return latestTag, nil
+}
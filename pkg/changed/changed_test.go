@@ -0,0 +1,135 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+type FakeGit struct {
+	tags    []string
+	changes map[string][]string
+}
+
+func (f *FakeGit) Tags(ctx context.Context, workingDir string) ([]string, error) {
+	return f.tags, nil
+}
+
+func (f *FakeGit) ChangedFiles(ctx context.Context, workingDir string, rev string, path string) ([]string, error) {
+	return f.changes[rev+":"+path], nil
+}
+
+func TestDetector_ChangedCharts(t *testing.T) {
+	git := &FakeGit{
+		tags: []string{"alpha-1.0.0", "alpha-1.1.0", "beta-0.1.0", "beta-0.2.0"},
+		changes: map[string][]string{
+			"beta-0.2.0:testdata/charts/beta": {"testdata/charts/beta/values.yaml"},
+		},
+	}
+	d := NewDetector(&config.Options{ChartsDir: "testdata/charts", ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}"}, git)
+
+	charts, err := d.ChangedCharts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*Chart{
+		{
+			Name:    "beta",
+			Version: "0.2.0",
+			Path:    "testdata/charts/beta",
+			Tag:     "beta-0.2.0",
+			Files:   []string{"testdata/charts/beta/values.yaml"},
+		},
+		{
+			Name:    "gamma",
+			Version: "0.1.0",
+			Path:    "testdata/charts/gamma",
+		},
+	}, charts)
+}
+
+func TestDetector_ChangedChartsMissingDir(t *testing.T) {
+	d := NewDetector(&config.Options{ChartsDir: "testdata/missing", ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}"}, &FakeGit{})
+	_, err := d.ChangedCharts(context.Background())
+	assert.Error(t, err)
+}
+
+func TestDetector_latestTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		tags     []string
+		expected string
+		error    bool
+	}{
+		{
+			name:     "highest-version",
+			template: "{{ .Name }}-{{ .Version }}",
+			tags:     []string{"test-chart-1.2.0", "test-chart-1.10.0", "test-chart-1.9.0"},
+			expected: "test-chart-1.10.0",
+		},
+		{
+			name:     "pre-release",
+			template: "{{ .Name }}-{{ .Version }}",
+			tags:     []string{"test-chart-2.0.0-rc.1", "test-chart-1.0.0"},
+			expected: "test-chart-2.0.0-rc.1",
+		},
+		{
+			name:     "ignores-other-charts",
+			template: "{{ .Name }}-{{ .Version }}",
+			tags:     []string{"test-chart-extra-3.0.0", "test-0.5.0", "test-chart-1.0.0", "v1.0.0"},
+			expected: "test-chart-1.0.0",
+		},
+		{
+			name:     "custom-template",
+			template: "{{ .Name }}/v{{ .Version }}",
+			tags:     []string{"test-chart-1.0.0", "test-chart/v0.9.0"},
+			expected: "test-chart/v0.9.0",
+		},
+		{
+			name:     "never-released",
+			template: "{{ .Name }}-{{ .Version }}",
+			tags:     []string{"other-chart-1.0.0"},
+			expected: "",
+		},
+		{
+			name:     "template-without-version",
+			template: "{{ .Name }}",
+			tags:     []string{"test-chart-1.0.0", "test-chart"},
+			expected: "test-chart",
+		},
+		{
+			name:     "invalid-template",
+			template: "{{ .Name ",
+			error:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(&config.Options{ReleaseNameTemplate: tt.template}, nil)
+			tag, err := d.latestTag("test-chart", tt.tags)
+			if tt.error {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tag)
+		})
+	}
+}
@@ -16,9 +16,11 @@ package config
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 
@@ -38,29 +40,139 @@ var (
 )
 
 type Options struct {
-	Owner                string `mapstructure:"owner"`
-	GitRepo              string `mapstructure:"git-repo"`
-	ChartsRepo           string `mapstructure:"charts-repo"`
-	IndexPath            string `mapstructure:"index-path"`
-	PackagePath          string `mapstructure:"package-path"`
-	Sign                 bool   `mapstructure:"sign"`
-	Key                  string `mapstructure:"key"`
-	KeyRing              string `mapstructure:"keyring"`
-	PassphraseFile       string `mapstructure:"passphrase-file"`
-	Token                string `mapstructure:"token"`
-	GitBaseURL           string `mapstructure:"git-base-url"`
-	GitUploadURL         string `mapstructure:"git-upload-url"`
-	Commit               string `mapstructure:"commit"`
-	PagesBranch          string `mapstructure:"pages-branch"`
-	PagesIndexPath       string `mapstructure:"pages-index-path"`
-	Push                 bool   `mapstructure:"push"`
-	PR                   bool   `mapstructure:"pr"`
-	Remote               string `mapstructure:"remote"`
-	ReleaseNameTemplate  string `mapstructure:"release-name-template"`
-	SkipExisting         bool   `mapstructure:"skip-existing"`
-	ReleaseNotesFile     string `mapstructure:"release-notes-file"`
-	GenerateReleaseNotes bool   `mapstructure:"generate-release-notes"`
-	MakeReleaseLatest    bool   `mapstructure:"make-release-latest"`
+	Owner                 string        `mapstructure:"owner"`
+	GitRepo               string        `mapstructure:"git-repo"`
+	ChartsRepo            string        `mapstructure:"charts-repo"`
+	IndexPath             string        `mapstructure:"index-path"`
+	PackagePath           string        `mapstructure:"package-path"`
+	Sign                  bool          `mapstructure:"sign"`
+	Key                   string        `mapstructure:"key"`
+	KeyRing               string        `mapstructure:"keyring"`
+	PassphraseFile        string        `mapstructure:"passphrase-file"`
+	Token                 string        `mapstructure:"token"`
+	GitBaseURL            string        `mapstructure:"git-base-url"`
+	GitUploadURL          string        `mapstructure:"git-upload-url"`
+	Commit                string        `mapstructure:"commit"`
+	PagesBranch           string        `mapstructure:"pages-branch"`
+	PagesIndexPath        string        `mapstructure:"pages-index-path"`
+	Push                  bool          `mapstructure:"push"`
+	PR                    bool          `mapstructure:"pr"`
+	Remote                string        `mapstructure:"remote"`
+	ReleaseNameTemplate   string        `mapstructure:"release-name-template"`
+	SkipExisting          bool          `mapstructure:"skip-existing"`
+	ReleaseNotesFile      string        `mapstructure:"release-notes-file"`
+	GenerateReleaseNotes  bool          `mapstructure:"generate-release-notes"`
+	MakeReleaseLatest     bool          `mapstructure:"make-release-latest"`
+	Timeout               time.Duration `mapstructure:"timeout"`
+	GitBackend            string        `mapstructure:"git-backend"`
+	CommitterName         string        `mapstructure:"committer-name"`
+	CommitterEmail        string        `mapstructure:"committer-email"`
+	SignCommits           bool          `mapstructure:"sign-commits"`
+	CommitSigningKey      string        `mapstructure:"commit-signing-key"`
+	CommitSigningFormat   string        `mapstructure:"commit-signing-format"`
+	CommitMessageTemplate string        `mapstructure:"commit-message-template"`
+	PRBranch              string        `mapstructure:"pr-branch"`
+	PRLabels              []string      `mapstructure:"pr-labels"`
+	PRReviewers           []string      `mapstructure:"pr-reviewers"`
+	PRTeamReviewers       []string      `mapstructure:"pr-team-reviewers"`
+	PRAutoMerge           bool          `mapstructure:"pr-auto-merge"`
+	PRMergeMethod         string        `mapstructure:"pr-merge-method"`
+	ChartsDir             string        `mapstructure:"charts-dir"`
+	Output                string        `mapstructure:"output"`
+	Exclude               []string      `mapstructure:"exclude"`
+	SkipLibraryCharts     bool          `mapstructure:"skip-library-charts"`
+	List                  bool          `mapstructure:"list"`
+	Concurrency           int           `mapstructure:"concurrency"`
+	Lint                  bool          `mapstructure:"lint"`
+	LintStrict            bool          `mapstructure:"lint-strict"`
+	RenderTest            bool          `mapstructure:"render-test"`
+	CheckAPIs             bool          `mapstructure:"check-apis"`
+	KubeVersion           string        `mapstructure:"kube-version"`
+	DeprecatedAPIs        string        `mapstructure:"deprecated-apis"`
+	RemovedAPIs           string        `mapstructure:"removed-apis"`
+	Version               string        `mapstructure:"version"`
+	AppVersion            string        `mapstructure:"app-version"`
+	SkipDependencyUpdate  bool          `mapstructure:"skip-dependency-update"`
+	Offline               bool          `mapstructure:"offline"`
+	Repositories          []Repository  `mapstructure:"repositories"`
+	SigningKeyEnv         string        `mapstructure:"signing-key-env"`
+	PassphraseEnv         string        `mapstructure:"passphrase-env"`
+	IndexURL              string        `mapstructure:"index-url"`
+	Reproducible          bool          `mapstructure:"reproducible"`
+	SBOM                  string        `mapstructure:"sbom"`
+	SBOMValues            []string      `mapstructure:"sbom-values"`
+	Policy                Policy        `mapstructure:"policy"`
+	Scan                  Scan          `mapstructure:"scan"`
+	Manifest              string        `mapstructure:"manifest"`
+	AllowBackport         bool          `mapstructure:"allow-backport"`
+	CheckValues           bool          `mapstructure:"check-values"`
+}
+
+// Repository configures access to a chart repository dependencies are
+// downloaded from. It is only read from the config file.
+type Repository struct {
+	// Name is optional. It allows referring to the repository as '@name' in
+	// Chart.yaml.
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	// Username and Password may reference environment variables, e.g.
+	// '${CHARTS_PASSWORD}'.
+	Username              string `mapstructure:"username"`
+	Password              string `mapstructure:"password"`
+	CAFile                string `mapstructure:"ca-file"`
+	CertFile              string `mapstructure:"cert-file"`
+	KeyFile               string `mapstructure:"key-file"`
+	InsecureSkipTLSVerify bool   `mapstructure:"insecure-skip-tls-verify"`
+}
+
+// Policy configures the metadata every chart must have to be packaged or
+// uploaded. Each rule is 'off', 'warning' or 'error'; rules are off unless
+// configured. It is only read from the config file.
+type Policy struct {
+	Maintainers string `mapstructure:"maintainers"`
+	Home        string `mapstructure:"home"`
+	Sources     string `mapstructure:"sources"`
+	Icon        string `mapstructure:"icon"`
+	License     string `mapstructure:"license"`
+	// AppVersion requires the appVersion to be a semantic version.
+	AppVersion string `mapstructure:"app-version"`
+	// LicenseAnnotation is the Chart.yaml annotation checked by the license
+	// rule, by default 'artifacthub.io/license'.
+	LicenseAnnotation string `mapstructure:"license-annotation"`
+}
+
+// Scan configures the inspection of chart packages for likely secrets and junk
+// files. It is only read from the config file.
+type Scan struct {
+	// MaxFileSize is the size in bytes above which files are reported, by
+	// default 1 MiB.
+	MaxFileSize int64                `mapstructure:"max-file-size"`
+	Allowlist   []ScanAllowlistEntry `mapstructure:"allowlist"`
+}
+
+// ScanAllowlistEntry allows files in chart packages which would be reported
+// by the scan.
+type ScanAllowlistEntry struct {
+	// Path is a glob pattern matched against the path in the package, e.g.
+	// 'mychart/files/test-ca.key'.
+	Path string `mapstructure:"path"`
+	// Rule restricts the entry to a rule, e.g. 'private-key'. Entries without
+	// a rule allow the files for all rules.
+	Rule string `mapstructure:"rule"`
+	// Reason documents why the files are allowed.
+	Reason string `mapstructure:"reason"`
+}
+
+// Rules returns the level of each policy rule by name.
+func (p *Policy) Rules() map[string]string {
+	return map[string]string{
+		"maintainers": p.Maintainers,
+		"home":        p.Home,
+		"sources":     p.Sources,
+		"icon":        p.Icon,
+		"license":     p.License,
+		"app-version": p.AppVersion,
+	}
 }
 
 func LoadConfiguration(cfgFile string, cmd *cobra.Command, requiredFlags []string) (*Options, error) {
@@ -107,6 +219,55 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, requiredFlags []strin
 		return nil, errors.New("specify either --push or --pr, but not both")
 	}
 
+	switch opts.PRMergeMethod {
+	case "", "merge", "squash", "rebase":
+	default:
+		return nil, errors.Errorf("unknown merge method %q, must be 'merge', 'squash' or 'rebase'", opts.PRMergeMethod)
+	}
+
+	switch opts.GitBackend {
+	case "", "exec", "go-git":
+	default:
+		return nil, errors.Errorf("unknown git backend %q, must be 'exec' or 'go-git'", opts.GitBackend)
+	}
+
+	if opts.GitBackend == "go-git" && opts.SignCommits && opts.CommitSigningFormat == "ssh" {
+		return nil, errors.New("SSH commit signing is not supported by the go-git backend, use --git-backend exec")
+	}
+
+	switch opts.Output {
+	case "", "text", "json":
+	default:
+		return nil, errors.Errorf("unknown output format %q, must be 'text' or 'json'", opts.Output)
+	}
+
+	switch opts.SBOM {
+	case "", "cyclonedx", "spdx":
+	default:
+		return nil, errors.Errorf("unknown SBOM format %q, must be 'cyclonedx' or 'spdx'", opts.SBOM)
+	}
+
+	if err := validateLevel("deprecated-apis", opts.DeprecatedAPIs); err != nil {
+		return nil, err
+	}
+	if err := validateLevel("removed-apis", opts.RemovedAPIs); err != nil {
+		return nil, err
+	}
+	for rule, level := range opts.Policy.Rules() {
+		if err := validateLevel("policy rule "+rule, level); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range opts.Scan.Allowlist {
+		if entry.Path == "" {
+			return nil, errors.New("scan allowlist entries need a path")
+		}
+		if _, err := path.Match(entry.Path, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid scan allowlist path %q", entry.Path)
+		}
+	}
+
 	elem := reflect.ValueOf(opts).Elem()
 	for _, requiredFlag := range requiredFlags {
 		fieldName := kebabCaseToTitleCamelCase(requiredFlag)
@@ -120,6 +281,16 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, requiredFlags []strin
 	return opts, nil
 }
 
+// validateLevel checks the severity level of a check.
+func validateLevel(name string, level string) error {
+	switch level {
+	case "", "off", "warning", "error":
+		return nil
+	default:
+		return errors.Errorf("unknown level %q for %s, must be 'off', 'warning' or 'error'", level, name)
+	}
+}
+
 func kebabCaseToTitleCamelCase(input string) (result string) {
 	nextToUpper := true
 	for _, runeValue := range input {
@@ -0,0 +1,90 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import "fmt"
+
+// Signing formats supported for commit signatures.
+const (
+	SigningFormatGPG = "gpg"
+	SigningFormatSSH = "ssh"
+)
+
+// Identity is the name and email commits are created with.
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// Signing configures how commits are signed.
+type Signing struct {
+	// Format is either SigningFormatGPG or SigningFormatSSH.
+	Format string
+	// Key is the signing key. For GPG this is a key ID when running the git
+	// binary and the path to an armored private key for go-git; for SSH it is
+	// the path to the key. If empty, git uses its configured default key.
+	Key string
+}
+
+// Validate checks that the signing configuration is complete.
+func (s *Signing) Validate() error {
+	switch s.Format {
+	case "", SigningFormatGPG:
+	case SigningFormatSSH:
+		if s.Key == "" {
+			return fmt.Errorf("a signing key is required for SSH commit signing")
+		}
+	default:
+		return fmt.Errorf("unknown signing format %q, must be '%s' or '%s'", s.Format, SigningFormatGPG, SigningFormatSSH)
+	}
+	return nil
+}
+
+// commitEnv returns the environment variables setting the commit identity,
+// which take precedence over any Git configuration.
+func (i Identity) commitEnv() []string {
+	var env []string
+	if i.Name != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+i.Name, "GIT_COMMITTER_NAME="+i.Name)
+	}
+	if i.Email != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+i.Email, "GIT_COMMITTER_EMAIL="+i.Email)
+	}
+	return env
+}
+
+// commitArgs returns the git arguments for 'git commit' with the given signing
+// configuration.
+func commitArgs(message string, signing *Signing) ([]string, error) {
+	var args []string
+	if signing != nil {
+		if err := signing.Validate(); err != nil {
+			return nil, err
+		}
+		if signing.Format == SigningFormatSSH {
+			args = append(args, "-c", "gpg.format=ssh", "-c", "user.signingkey="+signing.Key)
+		}
+	}
+
+	args = append(args, "commit", "--message", message, "--signoff")
+	if signing != nil {
+		if signing.Format != SigningFormatSSH && signing.Key != "" {
+			args = append(args, "--gpg-sign="+signing.Key)
+		} else {
+			args = append(args, "--gpg-sign")
+		}
+	}
+	return args, nil
+}
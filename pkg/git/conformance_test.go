@@ -0,0 +1,344 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend is the set of operations every Git implementation must support.
+type backend interface {
+	AddWorktree(ctx context.Context, workingDir string, committish string) (string, error)
+	RemoveWorktree(ctx context.Context, workingDir string, path string) error
+	Add(ctx context.Context, workingDir string, args ...string) error
+	Commit(ctx context.Context, workingDir string, message string) error
+	Push(ctx context.Context, workingDir string, args ...string) error
+	GetPushURL(ctx context.Context, remote string) (string, error)
+	Tags(ctx context.Context, workingDir string) ([]string, error)
+	ChangedFiles(ctx context.Context, workingDir string, rev string, path string) ([]string, error)
+	HeadMetadata(ctx context.Context, workingDir string) (*Metadata, error)
+}
+
+func TestBackendConformance(t *testing.T) {
+	backends := map[string]func(committer Identity) backend{
+		"exec":   func(committer Identity) backend { return &Git{Committer: committer} },
+		"go-git": func(committer Identity) backend { return &GoGit{Committer: committer} },
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			testBackend(t, newBackend)
+		})
+	}
+}
+
+func testBackend(t *testing.T, newBackend func(committer Identity) backend) {
+	ctx := context.Background()
+	remote, work := setupRepos(t)
+	chdir(t, work)
+	g := newBackend(Identity{})
+
+	t.Run("GetPushURL", func(t *testing.T) {
+		pushURL, err := g.GetPushURL(ctx, "origin")
+		require.NoError(t, err)
+		assert.Equal(t, remote, pushURL)
+
+		_, err = g.GetPushURL(ctx, "missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("Worktree", func(t *testing.T) {
+		headBefore := gitOutput(t, work, "rev-parse", "HEAD")
+
+		worktree, err := g.AddWorktree(ctx, work, "main")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(worktree, "README.md"))
+
+		indexYaml := filepath.Join(worktree, "index.yaml")
+		require.NoError(t, os.WriteFile(indexYaml, []byte("apiVersion: v1\n"), 0644))
+		require.NoError(t, g.Add(ctx, worktree, indexYaml))
+		require.NoError(t, g.Commit(ctx, worktree, "Update index.yaml"))
+		require.NoError(t, g.Push(ctx, worktree, remote, "HEAD:refs/heads/gh-pages"))
+
+		require.NoError(t, g.RemoveWorktree(ctx, work, worktree))
+		assert.NoDirExists(t, worktree)
+
+		// The repository the worktree was created from is left untouched.
+		assert.Equal(t, headBefore, gitOutput(t, work, "rev-parse", "HEAD"))
+		assert.Equal(t, "apiVersion: v1", gitOutput(t, remote, "show", "gh-pages:index.yaml"))
+		assert.Equal(t, headBefore, gitOutput(t, remote, "rev-parse", "gh-pages^"))
+
+		message := gitOutput(t, remote, "log", "-1", "--format=%B", "gh-pages")
+		assert.True(t, strings.HasPrefix(message, "Update index.yaml\n"))
+		assert.Contains(t, message, "Signed-off-by: Chart Releaser <no-reply@example.com>")
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(work, "index.yaml"), []byte("entries: {}\n"), 0644))
+		require.NoError(t, g.Add(ctx, "", "index.yaml"))
+		require.NoError(t, g.Commit(ctx, "", "Update index.yaml"))
+		require.NoError(t, g.Push(ctx, "", remote, "HEAD:refs/heads/main"))
+
+		assert.Equal(t, gitOutput(t, work, "rev-parse", "HEAD"), gitOutput(t, remote, "rev-parse", "main"))
+		assert.Equal(t, "entries: {}", gitOutput(t, remote, "show", "main:index.yaml"))
+	})
+
+	t.Run("Committer", func(t *testing.T) {
+		g := newBackend(Identity{Name: "Index Bot", Email: "bot@example.com"})
+		require.NoError(t, os.WriteFile(filepath.Join(work, "index.yaml"), []byte("entries: []\n"), 0644))
+		require.NoError(t, g.Add(ctx, work, "index.yaml"))
+		require.NoError(t, g.Commit(ctx, work, "Update index.yaml"))
+
+		assert.Equal(t, "Index Bot <bot@example.com>", gitOutput(t, work, "log", "-1", "--format=%an <%ae>"))
+		assert.Equal(t, "Index Bot <bot@example.com>", gitOutput(t, work, "log", "-1", "--format=%cn <%ce>"))
+		assert.Contains(t, gitOutput(t, work, "log", "-1", "--format=%B"), "Signed-off-by: Index Bot <bot@example.com>")
+	})
+
+	t.Run("ChangedFiles", func(t *testing.T) {
+		chart := filepath.Join(work, "charts", "test-chart")
+		require.NoError(t, os.MkdirAll(filepath.Join(chart, "templates"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("name: test-chart\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(chart, "values.yaml"), []byte("replicas: 1\n"), 0644))
+		gitRun(t, work, "add", "charts")
+		gitRun(t, work, "commit", "--message", "Add test-chart")
+		gitRun(t, work, "tag", "--annotate", "--message", "test-chart-0.1.0", "test-chart-0.1.0")
+		gitRun(t, work, "tag", "other-chart-1.0.0")
+
+		tags, err := g.Tags(ctx, work)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"other-chart-1.0.0", "test-chart-0.1.0"}, tags)
+
+		files, err := g.ChangedFiles(ctx, work, "test-chart-0.1.0", "charts/test-chart")
+		require.NoError(t, err)
+		assert.Empty(t, files)
+
+		require.NoError(t, os.WriteFile(filepath.Join(chart, "templates", "service.yaml"), []byte("kind: Service\n"), 0644))
+		require.NoError(t, os.Remove(filepath.Join(chart, "values.yaml")))
+		require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte("# Charts\n"), 0644))
+		gitRun(t, work, "add", "--all")
+		gitRun(t, work, "commit", "--message", "Update test-chart")
+
+		files, err = g.ChangedFiles(ctx, work, "test-chart-0.1.0", "charts/test-chart")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"charts/test-chart/templates/service.yaml", "charts/test-chart/values.yaml"}, files)
+
+		// Paths are relative to workingDir, results relative to the repository root.
+		files, err = g.ChangedFiles(ctx, filepath.Join(work, "charts"), "test-chart-0.1.0", "test-chart/templates")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"charts/test-chart/templates/service.yaml"}, files)
+
+		_, err = g.ChangedFiles(ctx, work, "missing-0.1.0", "charts/test-chart")
+		assert.Error(t, err)
+	})
+
+	t.Run("HeadMetadata", func(t *testing.T) {
+		repo := t.TempDir()
+		gitRun(t, repo, "init", "--initial-branch=main")
+		gitRun(t, repo, "config", "user.name", "Chart Releaser")
+		gitRun(t, repo, "config", "user.email", "no-reply@example.com")
+		commit := func(message string, date string) {
+			command := exec.Command("git", "commit", "--allow-empty", "--message", message)
+			command.Dir = repo
+			command.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+date, "GIT_AUTHOR_DATE="+date)
+			out, err := command.CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+
+		commit("Initial commit", "2023-01-01T10:00:00Z")
+		metadata, err := g.HeadMetadata(ctx, repo)
+		require.NoError(t, err)
+		head := gitOutput(t, repo, "rev-parse", "HEAD")
+		assert.Equal(t, &Metadata{
+			Commit:          head,
+			ShortCommit:     head[:7],
+			Branch:          "main",
+			CommitsSinceTag: 1,
+			CommitTime:      time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC),
+		}, metadata)
+		assert.Equal(t, head[:7], metadata.Describe())
+
+		gitRun(t, repo, "tag", "--annotate", "--message", "v1.2.0", "v1.2.0")
+		commit("Second commit", "2023-01-02T10:00:00Z")
+		gitRun(t, repo, "tag", "v1.3.0")
+		commit("Third commit", "2023-01-03T10:00:00Z")
+		commit("Fourth commit", "2023-01-04T10:00:00Z")
+		gitRun(t, repo, "checkout", "--detach", "HEAD")
+
+		metadata, err = g.HeadMetadata(ctx, repo)
+		require.NoError(t, err)
+		head = gitOutput(t, repo, "rev-parse", "HEAD")
+		assert.Equal(t, "", metadata.Branch)
+		assert.Equal(t, "v1.3.0", metadata.Tag)
+		assert.Equal(t, 2, metadata.CommitsSinceTag)
+		assert.Equal(t, time.Date(2023, 1, 4, 10, 0, 0, 0, time.UTC), metadata.CommitTime)
+		assert.Equal(t, gitOutput(t, repo, "describe", "--tags", "--always"), metadata.Describe())
+		assert.Equal(t, "v1.3.0-2-g"+head[:7], metadata.Describe())
+
+		gitRun(t, repo, "checkout", "v1.2.0")
+		metadata, err = g.HeadMetadata(ctx, repo)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.2.0", metadata.Describe())
+	})
+
+	t.Run("AddWithoutArgs", func(t *testing.T) {
+		assert.Error(t, g.Add(ctx, ""))
+	})
+}
+
+// setupRepos creates a bare repository and a clone of it with one commit on
+// the main branch, and returns their paths.
+func setupRepos(t *testing.T) (string, string) {
+	remote := t.TempDir()
+	work := t.TempDir()
+
+	gitRun(t, remote, "init", "--bare", "--initial-branch=main")
+	gitRun(t, work, "init", "--initial-branch=main")
+	gitRun(t, work, "config", "user.name", "Chart Releaser")
+	gitRun(t, work, "config", "user.email", "no-reply@example.com")
+	gitRun(t, work, "config", "commit.gpgsign", "false")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte("# charts\n"), 0644))
+	gitRun(t, work, "add", "README.md")
+	gitRun(t, work, "commit", "--message", "Initial commit")
+	gitRun(t, work, "remote", "add", "origin", remote)
+	gitRun(t, work, "push", "origin", "main")
+
+	return remote, work
+}
+
+func chdir(t *testing.T, dir string) {
+	curDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(curDir))
+	})
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	gitOutput(t, dir, args...)
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	out, err := command.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func TestGoGit_SignedCommit(t *testing.T) {
+	ctx := context.Background()
+	_, work := setupRepos(t)
+
+	entity, err := openpgp.NewEntity("Chart Releaser", "", "no-reply@example.com", nil)
+	require.NoError(t, err)
+	var publicKey bytes.Buffer
+	w, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	keyFile := writePrivateKey(t, entity)
+
+	g := &GoGit{Signing: &Signing{Format: SigningFormatGPG, Key: keyFile}}
+	require.NoError(t, os.WriteFile(filepath.Join(work, "index.yaml"), []byte("entries: {}\n"), 0644))
+	require.NoError(t, g.Add(ctx, work, "index.yaml"))
+	require.NoError(t, g.Commit(ctx, work, "Update index.yaml"))
+
+	repo, err := gogit.PlainOpen(work)
+	require.NoError(t, err)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	commit, err := repo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	_, err = commit.Verify(publicKey.String())
+	require.NoError(t, err)
+
+	g.Signing.Format = SigningFormatSSH
+	require.Error(t, g.Commit(ctx, work, "Update index.yaml"))
+}
+
+// writePrivateKey writes the armored private key of entity to a file and
+// returns its path.
+func writePrivateKey(t *testing.T, entity *openpgp.Entity) string {
+	var privateKey bytes.Buffer
+	w, err := armor.Encode(&privateKey, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivateWithoutSigning(w, nil))
+	require.NoError(t, w.Close())
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(keyFile, privateKey.Bytes(), 0600))
+	return keyFile
+}
+
+func TestSigning_ValidateGoGit(t *testing.T) {
+	entity, err := openpgp.NewEntity("Chart Releaser", "", "no-reply@example.com", nil)
+	require.NoError(t, err)
+	keyFile := writePrivateKey(t, entity)
+
+	encrypted, err := openpgp.NewEntity("Chart Releaser", "", "no-reply@example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, encrypted.EncryptPrivateKeys([]byte("secret"), nil))
+	encryptedKeyFile := writePrivateKey(t, encrypted)
+
+	tests := []struct {
+		name    string
+		signing *Signing
+		error   string
+	}{
+		{
+			name:    "gpg",
+			signing: &Signing{Format: SigningFormatGPG, Key: keyFile},
+		},
+		{
+			name:    "ssh",
+			signing: &Signing{Format: SigningFormatSSH, Key: "~/.ssh/id_ed25519"},
+			error:   "SSH commit signing is not supported by the go-git backend",
+		},
+		{
+			name:    "no-key",
+			signing: &Signing{Format: SigningFormatGPG},
+			error:   "the go-git backend requires the path to an armored private key",
+		},
+		{
+			name:    "passphrase",
+			signing: &Signing{Format: SigningFormatGPG, Key: encryptedKeyFile},
+			error:   "is protected by a passphrase, which is not supported by the go-git backend",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signing.ValidateGoGit()
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -15,69 +15,166 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
-type Git struct{}
+// tokenEnvVar is the environment variable the credential helper reads the
+// token from, so that it never shows up in a URL or on the command line.
+const tokenEnvVar = "CR_GIT_TOKEN"
+
+// credentialHelper answers Git's credential requests with the token. It is
+// only consulted for HTTP(S) remotes; SSH remotes use the SSH agent or keys.
+const credentialHelper = `!f() { test "$1" = get && echo username=x-access-token && echo "password=${` + tokenEnvVar + `}"; }; f`
+
+// Git runs operations using the git binary.
+type Git struct {
+	// Token is used to authenticate pushes to HTTP(S) remotes. If empty, the
+	// credential helpers or GIT_ASKPASS configured in the environment are used.
+	Token string
+	// Committer overrides the identity from the Git configuration.
+	Committer Identity
+	// Signing enables signing of commits.
+	Signing *Signing
+}
 
 // AddWorktree creates a new Git worktree with a detached HEAD for the given committish and returns its path.
-func (g *Git) AddWorktree(workingDir string, committish string) (string, error) {
+// The temporary directory is removed again if the worktree cannot be created.
+func (g *Git) AddWorktree(ctx context.Context, workingDir string, committish string) (string, error) {
 	dir, err := os.MkdirTemp("", "chart-releaser-")
 	if err != nil {
 		return "", err
 	}
-	command := exec.Command("git", "worktree", "add", "--detach", dir, committish)
+	command := exec.CommandContext(ctx, "git", "worktree", "add", "--detach", dir, committish)
 
 	if err := runCommand(workingDir, command); err != nil {
+		os.RemoveAll(dir)
 		return "", err
 	}
 	return dir, nil
 }
 
-// RemoveWorktree removes the Git worktree with the given path.
-func (g *Git) RemoveWorktree(workingDir string, path string) error {
-	command := exec.Command("git", "worktree", "remove", path, "--force")
-	return runCommand(workingDir, command)
+// RemoveWorktree removes the Git worktree with the given path. The directory is
+// deleted even if Git fails to unregister the worktree, e.g. because ctx is
+// already cancelled; 'git worktree prune' cleans up the leftover metadata.
+func (g *Git) RemoveWorktree(ctx context.Context, workingDir string, path string) error {
+	command := exec.CommandContext(ctx, "git", "worktree", "remove", path, "--force")
+	err := runCommand(workingDir, command)
+	if rmErr := os.RemoveAll(path); err == nil {
+		err = rmErr
+	}
+	return err
 }
 
 // Add runs 'git add' with the given args.
-func (g *Git) Add(workingDir string, args ...string) error {
+func (g *Git) Add(ctx context.Context, workingDir string, args ...string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("no args specified")
 	}
 	addArgs := []string{"add"}
 	addArgs = append(addArgs, args...)
-	command := exec.Command("git", addArgs...)
+	command := exec.CommandContext(ctx, "git", addArgs...)
 	return runCommand(workingDir, command)
 }
 
-// Commit runs 'git commit' with the given message. the commit is signed off.
-func (g *Git) Commit(workingDir string, message string) error {
-	command := exec.Command("git", "commit", "--message", message, "--signoff")
+// Commit runs 'git commit' with the given message. the commit is signed off,
+// and signed if Signing is set.
+func (g *Git) Commit(ctx context.Context, workingDir string, message string) error {
+	args, err := commitArgs(message, g.Signing)
+	if err != nil {
+		return err
+	}
+	command := exec.CommandContext(ctx, "git", args...)
+	if env := g.Committer.commitEnv(); len(env) > 0 {
+		command.Env = append(os.Environ(), env...)
+	}
 	return runCommand(workingDir, command)
 }
 
-// Push runs 'git push' with the given args.
-func (g *Git) Push(workingDir string, args ...string) error {
-	pushArgs := []string{"push"}
+// Push runs 'git push' with the given args. If a token is set, it is handed to
+// Git through a credential helper which replaces any configured ones.
+func (g *Git) Push(ctx context.Context, workingDir string, args ...string) error {
+	var pushArgs []string
+	if g.Token != "" {
+		pushArgs = append(pushArgs, "-c", "credential.helper=", "-c", "credential.helper="+credentialHelper)
+	}
+	pushArgs = append(pushArgs, "push")
 	pushArgs = append(pushArgs, args...)
-	command := exec.Command("git", pushArgs...)
+	command := exec.CommandContext(ctx, "git", pushArgs...)
+	if g.Token != "" {
+		command.Env = append(os.Environ(), tokenEnvVar+"="+g.Token)
+	}
 	return runCommand(workingDir, command)
 }
 
-// GetPushURL returns the push url with a token inserted
-func (g *Git) GetPushURL(remote string, token string) (string, error) {
-	pushURL, err := exec.Command("git", "remote", "get-url", "--push", remote).Output()
+// GetPushURL returns the push URL of the given remote. HTTP(S) and SSH remotes,
+// including the scp-like 'git@host:owner/repo' syntax, are supported.
+func (g *Git) GetPushURL(ctx context.Context, remote string) (string, error) {
+	pushURL, err := exec.CommandContext(ctx, "git", "remote", "get-url", "--push", remote).Output()
 	if err != nil {
 		return "", err
 	}
 
-	pushURLArray := strings.SplitAfter(strings.TrimSpace(string(pushURL)), "https://")
-	pushURLWithToken := fmt.Sprintf("https://x-access-token:%s@%s", token, pushURLArray[1])
-	return pushURLWithToken, nil
+	remoteURL, err := ParseRemoteURL(string(pushURL))
+	if err != nil {
+		return "", err
+	}
+	return remoteURL.String(), nil
+}
+
+// Tags returns the names of all tags of the repository in workingDir.
+func (g *Git) Tags(ctx context.Context, workingDir string) ([]string, error) {
+	command := exec.CommandContext(ctx, "git", "tag", "--list")
+	return outputLines(workingDir, command)
+}
+
+// ChangedFiles returns the files below path that differ between the given
+// revision and HEAD. Paths are relative to the root of the repository.
+func (g *Git) ChangedFiles(ctx context.Context, workingDir string, rev string, path string) ([]string, error) {
+	command := exec.CommandContext(ctx, "git", "diff", "--name-only", "--no-renames", rev, "HEAD", "--", path)
+	return outputLines(workingDir, command)
+}
+
+// HeadMetadata returns metadata about the commit HEAD points to.
+func (g *Git) HeadMetadata(ctx context.Context, workingDir string) (*Metadata, error) {
+	commit, err := output(workingDir, exec.CommandContext(ctx, "git", "rev-parse", "--verify", "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	metadata := &Metadata{Commit: commit, ShortCommit: commit[:shortCommitLength]}
+
+	// Both fail if HEAD is detached or there is no tag, respectively.
+	metadata.Branch, _ = output(workingDir, exec.CommandContext(ctx, "git", "symbolic-ref", "--quiet", "--short", "HEAD"))
+	metadata.Tag, _ = output(workingDir, exec.CommandContext(ctx, "git", "describe", "--tags", "--abbrev=0", "HEAD"))
+
+	revisions := "HEAD"
+	if metadata.Tag != "" {
+		revisions = metadata.Tag + "..HEAD"
+	}
+	count, err := output(workingDir, exec.CommandContext(ctx, "git", "rev-list", "--count", revisions))
+	if err != nil {
+		return nil, err
+	}
+	if metadata.CommitsSinceTag, err = strconv.Atoi(count); err != nil {
+		return nil, err
+	}
+
+	commitTime, err := output(workingDir, exec.CommandContext(ctx, "git", "log", "-1", "--format=%ct", "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := strconv.ParseInt(commitTime, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	metadata.CommitTime = time.Unix(seconds, 0).UTC()
+	return metadata, nil
 }
 
 func runCommand(workingDir string, command *exec.Cmd) error {
@@ -86,3 +183,34 @@ func runCommand(workingDir string, command *exec.Cmd) error {
 	command.Stderr = os.Stderr
 	return command.Run()
 }
+
+func outputLines(workingDir string, command *exec.Cmd) ([]string, error) {
+	command.Dir = workingDir
+	command.Stderr = os.Stderr
+	out, err := command.Output()
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// output runs the command and returns its trimmed output. Errors include what
+// the command printed to stderr.
+func output(workingDir string, command *exec.Cmd) (string, error) {
+	command.Dir = workingDir
+	out, err := command.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
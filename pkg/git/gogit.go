@@ -0,0 +1,688 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// GoGit implements the same operations as Git in pure Go, so that no git
+// binary is required.
+type GoGit struct {
+	// Token is used to authenticate pushes to HTTP(S) remotes. SSH remotes
+	// authenticate through the SSH agent.
+	Token string
+	// Committer overrides the identity from the Git configuration.
+	Committer Identity
+	// Signing enables signing of commits. Only GPG signing with an unencrypted
+	// armored private key file is supported, see Signing.ValidateGoGit.
+	Signing *Signing
+
+	mu        sync.Mutex
+	worktrees map[string]*gogit.Repository
+}
+
+// AddWorktree checks out the given committish with a detached HEAD into a new
+// temporary directory and returns its path. Like a Git worktree, it shares the
+// object database and branches with the repository in workingDir, but has its
+// own HEAD and index.
+func (g *GoGit) AddWorktree(ctx context.Context, workingDir string, committish string) (string, error) {
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(committish))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %w", committish, err)
+	}
+	shared, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", fmt.Errorf("repository in %q does not use filesystem storage", workingDir)
+	}
+
+	dir, err := os.MkdirTemp("", "chart-releaser-")
+	if err != nil {
+		return "", err
+	}
+
+	worktreeRepo, err := gogit.Open(&worktreeStorage{
+		Storage: shared,
+		head:    plumbing.NewHashReference(plumbing.HEAD, *hash),
+	}, osfs.New(dir))
+	if err == nil {
+		err = checkout(ctx, worktreeRepo, *hash)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.worktrees == nil {
+		g.worktrees = map[string]*gogit.Repository{}
+	}
+	g.worktrees[dir] = worktreeRepo
+	return dir, nil
+}
+
+// RemoveWorktree removes the worktree with the given path.
+func (g *GoGit) RemoveWorktree(ctx context.Context, workingDir string, path string) error {
+	g.mu.Lock()
+	delete(g.worktrees, path)
+	g.mu.Unlock()
+	return os.RemoveAll(path)
+}
+
+// Add adds the given paths to the index.
+func (g *GoGit) Add(ctx context.Context, workingDir string, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no args specified")
+	}
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	root := worktree.Filesystem.Root()
+	base, err := filepath.Abs(workingDir)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := arg
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("%s is outside of the worktree %s", arg, root)
+		}
+		if _, err := worktree.Add(filepath.ToSlash(rel)); err != nil {
+			return fmt.Errorf("failed to add %s: %w", arg, err)
+		}
+	}
+	return nil
+}
+
+// Commit commits the index with the given message. The commit is signed off,
+// and signed if Signing is set.
+func (g *GoGit) Commit(ctx context.Context, workingDir string, message string) error {
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	signature, err := g.identity(repo)
+	if err != nil {
+		return err
+	}
+	signKey, err := g.signKey()
+	if err != nil {
+		return err
+	}
+
+	message = fmt.Sprintf("%s\n\nSigned-off-by: %s <%s>\n", strings.TrimRight(message, "\n"), signature.Name, signature.Email)
+	hash, err := worktree.Commit(message, &gogit.CommitOptions{
+		Author:    signature,
+		Committer: signature,
+		SignKey:   signKey,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created commit %s\n", hash)
+	return nil
+}
+
+// Push pushes to the remote URL given as the first argument. The remaining
+// arguments are refspecs; their source may be any revision, e.g. 'HEAD'.
+func (g *GoGit) Push(ctx context.Context, workingDir string, args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("push requires a remote URL and at least one refspec")
+	}
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return err
+	}
+	remoteURL, err := ParseRemoteURL(args[0])
+	if err != nil {
+		return err
+	}
+
+	var refSpecs []config.RefSpec
+	for _, arg := range args[1:] {
+		refSpec, err := resolveRefSpec(repo, arg)
+		if err != nil {
+			return err
+		}
+		refSpecs = append(refSpecs, refSpec)
+	}
+
+	var auth transport.AuthMethod
+	if remoteURL.IsHTTP() && g.Token != "" {
+		auth = &http.BasicAuth{Username: "x-access-token", Password: g.Token}
+	}
+
+	remote := gogit.NewRemote(repo.Storer, &config.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{remoteURL.String()},
+	})
+	err = remote.PushContext(ctx, &gogit.PushOptions{
+		RemoteName: "anonymous",
+		RefSpecs:   refSpecs,
+		Auth:       auth,
+		Progress:   os.Stdout,
+	})
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// GetPushURL returns the push URL of the given remote of the repository in
+// the current directory.
+func (g *GoGit) GetPushURL(ctx context.Context, remote string) (string, error) {
+	repo, err := g.open("")
+	if err != nil {
+		return "", err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", err
+	}
+	remoteConfig, ok := cfg.Remotes[remote]
+	if !ok || len(remoteConfig.URLs) == 0 {
+		return "", fmt.Errorf("no such remote %q", remote)
+	}
+
+	pushURL := remoteConfig.URLs[0]
+	// go-git does not model pushurl, so read it from the raw config.
+	if raw := cfg.Raw.Section("remote").Subsection(remote).Option("pushurl"); raw != "" {
+		pushURL = raw
+	}
+	remoteURL, err := ParseRemoteURL(pushURL)
+	if err != nil {
+		return "", err
+	}
+	return remoteURL.String(), nil
+}
+
+// Tags returns the names of all tags of the repository in workingDir.
+func (g *GoGit) Tags(ctx context.Context, workingDir string) ([]string, error) {
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+	sort.Strings(tags)
+	return tags, err
+}
+
+// ChangedFiles returns the files below path that differ between the given
+// revision and HEAD. Paths are relative to the root of the repository.
+func (g *GoGit) ChangedFiles(ctx context.Context, workingDir string, rev string, path string) ([]string, error) {
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := repoRelativePath(repo, filepath.Join(workingDir, path))
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := commitTree(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	to, err := commitTree(repo, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, from, to, &object.DiffTreeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var files []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name == "" || seen[name] || !isBelow(name, prefix) {
+				continue
+			}
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// HeadMetadata returns metadata about the commit HEAD points to. The most
+// recent tag is the first tagged commit in committer date order, which matches
+// 'git describe' for linear history.
+func (g *GoGit) HeadMetadata(ctx context.Context, workingDir string) (*Metadata, error) {
+	repo, err := g.open(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{
+		Commit:      commit.Hash.String(),
+		ShortCommit: commit.Hash.String()[:shortCommitLength],
+		CommitTime:  commit.Committer.When.UTC(),
+	}
+	if head.Name().IsBranch() {
+		metadata.Branch = head.Name().Short()
+	}
+
+	tagged, err := taggedCommits(repo)
+	if err != nil {
+		return nil, err
+	}
+	var tagCommit plumbing.Hash
+	err = walkCommits(ctx, repo, commit.Hash, gogit.LogOrderCommitterTime, func(c *object.Commit) error {
+		if tags, ok := tagged[c.Hash]; ok {
+			metadata.Tag = tags[0]
+			tagCommit = c.Hash
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Count the commits reachable from HEAD but not from the tag.
+	excluded := map[plumbing.Hash]bool{}
+	if !tagCommit.IsZero() {
+		err = walkCommits(ctx, repo, tagCommit, gogit.LogOrderDFS, func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = walkCommits(ctx, repo, commit.Hash, gogit.LogOrderDFS, func(c *object.Commit) error {
+		if !excluded[c.Hash] {
+			metadata.CommitsSinceTag++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// open returns the repository for workingDir, which is either one of our
+// worktrees or a regular repository.
+func (g *GoGit) open(workingDir string) (*gogit.Repository, error) {
+	g.mu.Lock()
+	repo, ok := g.worktrees[workingDir]
+	g.mu.Unlock()
+	if ok {
+		return repo, nil
+	}
+
+	if workingDir == "" {
+		workingDir = "."
+	}
+	return gogit.PlainOpenWithOptions(workingDir, &gogit.PlainOpenOptions{DetectDotGit: true})
+}
+
+// taggedCommits returns the names of the tags pointing to each commit, in
+// lexical order. Annotated tags are peeled.
+func taggedCommits(repo *gogit.Repository) (map[plumbing.Hash][]string, error) {
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	tagged := map[plumbing.Hash][]string{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// The tag does not point to a commit.
+				return nil
+			}
+			hash = commit.Hash
+		}
+		tagged[hash] = append(tagged[hash], ref.Name().Short())
+		return nil
+	})
+	for _, tags := range tagged {
+		sort.Strings(tags)
+	}
+	return tagged, err
+}
+
+// walkCommits calls fn for every commit reachable from the given one until fn
+// returns storer.ErrStop or ctx is cancelled.
+func walkCommits(ctx context.Context, repo *gogit.Repository, from plumbing.Hash, order gogit.LogOrder, fn func(*object.Commit) error) error {
+	iter, err := repo.Log(&gogit.LogOptions{From: from, Order: order})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	return iter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(c)
+	})
+}
+
+// commitTree returns the tree of the commit the given revision points to.
+// Annotated tags are peeled like git does.
+func commitTree(repo *gogit.Repository, rev string) (*object.Tree, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", rev, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// repoRelativePath returns path relative to the root of the repository's
+// worktree, using slashes as Git does.
+func repoRelativePath(repo *gogit.Repository, path string) (string, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(worktree.Filesystem.Root())
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// isBelow reports whether name is dir or a file below it. The root of the
+// repository is ".".
+func isBelow(name string, dir string) bool {
+	return dir == "." || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+func checkout(ctx context.Context, repo *gogit.Repository, hash plumbing.Hash) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&gogit.CheckoutOptions{Hash: hash, Force: true})
+}
+
+// resolveRefSpec turns 'src:dst' into a refspec go-git can push. go-git only
+// matches references by their full name, so any other source is resolved to a
+// commit hash.
+func resolveRefSpec(repo *gogit.Repository, arg string) (config.RefSpec, error) {
+	force := strings.HasPrefix(arg, "+")
+	src, dst, found := strings.Cut(strings.TrimPrefix(arg, "+"), ":")
+	if !found {
+		dst = src
+	}
+	if !strings.HasPrefix(src, "refs/") {
+		hash, err := repo.ResolveRevision(plumbing.Revision(src))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %q: %w", src, err)
+		}
+		src = hash.String()
+	}
+	if !strings.HasPrefix(dst, "refs/") {
+		dst = "refs/heads/" + dst
+	}
+
+	refSpec := config.RefSpec(src + ":" + dst)
+	if force {
+		refSpec = "+" + refSpec
+	}
+	return refSpec, refSpec.Validate()
+}
+
+// identity returns the signature for new commits from the configured committer,
+// the environment or the Git configuration, in the same order of precedence as
+// git.
+func (g *GoGit) identity(repo *gogit.Repository) (*object.Signature, error) {
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	name := firstNonEmpty(g.Committer.Name, os.Getenv("GIT_AUTHOR_NAME"), cfg.Author.Name, cfg.User.Name)
+	email := firstNonEmpty(g.Committer.Email, os.Getenv("GIT_AUTHOR_EMAIL"), cfg.Author.Email, cfg.User.Email)
+	if name == "" || email == "" {
+		return nil, errors.New("author identity unknown: set user.name and user.email in the Git configuration")
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+// signKey loads the private key commits are signed with, if signing is enabled.
+func (g *GoGit) signKey() (*openpgp.Entity, error) {
+	if g.Signing == nil {
+		return nil, nil
+	}
+	return g.Signing.goGitKey()
+}
+
+// ValidateGoGit checks that commits can be signed by the go-git backend, which
+// only supports GPG signing with an armored private key file that is not
+// protected by a passphrase. It is meant to be called when the configuration
+// is loaded, before any work is done.
+func (s *Signing) ValidateGoGit() error {
+	_, err := s.goGitKey()
+	return err
+}
+
+func (s *Signing) goGitKey() (*openpgp.Entity, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if s.Format == SigningFormatSSH {
+		return nil, errors.New("SSH commit signing is not supported by the go-git backend, use --git-backend exec")
+	}
+	if s.Key == "" {
+		return nil, errors.New("the go-git backend requires the path to an armored private key for signing commits")
+	}
+
+	f, err := os.Open(s.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signing key: %w", err)
+	}
+	defer f.Close()
+	entities, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", s.Key, err)
+	}
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			return nil, fmt.Errorf("signing key %s is protected by a passphrase, which is not supported by the go-git backend, use --git-backend exec", s.Key)
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("no private key found in %s", s.Key)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// worktreeStorage shares objects, branches and configuration with a repository
+// but keeps its own HEAD and index, like a linked Git worktree.
+type worktreeStorage struct {
+	*filesystem.Storage
+
+	mu    sync.Mutex
+	head  *plumbing.Reference
+	index memory.IndexStorage
+}
+
+func (s *worktreeStorage) SetReference(ref *plumbing.Reference) error {
+	if ref.Name() != plumbing.HEAD {
+		return s.Storage.SetReference(ref)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.head = ref
+	return nil
+}
+
+func (s *worktreeStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	if ref.Name() != plumbing.HEAD {
+		return s.Storage.CheckAndSetReference(ref, old)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old != nil && (s.head == nil || s.head.Hash() != old.Hash()) {
+		return storage.ErrReferenceHasChanged
+	}
+	s.head = ref
+	return nil
+}
+
+func (s *worktreeStorage) Reference(name plumbing.ReferenceName) (*plumbing.Reference, error) {
+	if name != plumbing.HEAD {
+		return s.Storage.Reference(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.head == nil {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+	return s.head, nil
+}
+
+func (s *worktreeStorage) RemoveReference(name plumbing.ReferenceName) error {
+	if name != plumbing.HEAD {
+		return s.Storage.RemoveReference(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.head = nil
+	return nil
+}
+
+func (s *worktreeStorage) IterReferences() (storer.ReferenceIter, error) {
+	iter, err := s.Storage.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var refs []*plumbing.Reference
+	for {
+		ref, err := iter.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ref.Name() != plumbing.HEAD {
+			refs = append(refs, ref)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.head != nil {
+		refs = append(refs, s.head)
+	}
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+func (s *worktreeStorage) SetIndex(idx *index.Index) error {
+	return s.index.SetIndex(idx)
+}
+
+func (s *worktreeStorage) Index() (*index.Index, error) {
+	return s.index.Index()
+}
@@ -0,0 +1,55 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"fmt"
+	"time"
+)
+
+// shortCommitLength is the length of abbreviated commit hashes.
+const shortCommitLength = 7
+
+// Metadata describes the commit HEAD points to.
+type Metadata struct {
+	// Commit is the full hash of the commit.
+	Commit string
+	// ShortCommit is the abbreviated hash of the commit.
+	ShortCommit string
+	// Branch is the checked out branch, or empty if HEAD is detached.
+	Branch string
+	// Tag is the most recent tag reachable from the commit, or empty if
+	// there is none.
+	Tag string
+	// CommitsSinceTag is the number of commits since Tag, or since the root
+	// commit if there is no tag.
+	CommitsSinceTag int
+	// CommitTime is the committer date of the commit.
+	CommitTime time.Time
+}
+
+// Describe returns the same as 'git describe --tags --always': the tag if it
+// points to the commit, '<tag>-<n>-g<short commit>' if there are commits since
+// the tag, or the short commit if there is no tag.
+func (m *Metadata) Describe() string {
+	switch {
+	case m.Tag == "":
+		return m.ShortCommit
+	case m.CommitsSinceTag == 0:
+		return m.Tag
+	default:
+		return fmt.Sprintf("%s-%d-g%s", m.Tag, m.CommitsSinceTag, m.ShortCommit)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RemoteURL is a parsed Git remote URL. Besides regular URLs it supports the
+// scp-like syntax 'user@host:path' and local paths.
+type RemoteURL struct {
+	Scheme string
+	User   *url.Userinfo
+	Host   string
+	Path   string
+
+	scpLike bool
+	local   bool
+}
+
+// ParseRemoteURL parses a Git remote URL.
+func ParseRemoteURL(raw string) (*RemoteURL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, fmt.Errorf("empty remote URL")
+	}
+
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote URL %q: %w", raw, err)
+		}
+		if u.Scheme != "file" && u.Host == "" {
+			return nil, fmt.Errorf("invalid remote URL %q: missing host", raw)
+		}
+		return &RemoteURL{
+			Scheme: u.Scheme,
+			User:   u.User,
+			Host:   u.Host,
+			Path:   u.Path,
+		}, nil
+	}
+
+	// A colon before the first slash denotes the scp-like syntax, anything else
+	// is a local path.
+	colon := strings.Index(raw, ":")
+	if colon > 0 && !strings.Contains(raw[:colon], "/") {
+		remote := &RemoteURL{
+			Scheme:  "ssh",
+			Host:    raw[:colon],
+			Path:    raw[colon+1:],
+			scpLike: true,
+		}
+		if at := strings.LastIndex(remote.Host, "@"); at >= 0 {
+			remote.User = url.User(remote.Host[:at])
+			remote.Host = remote.Host[at+1:]
+		}
+		if remote.Host == "" || remote.Path == "" {
+			return nil, fmt.Errorf("invalid remote URL %q", raw)
+		}
+		return remote, nil
+	}
+
+	return &RemoteURL{Scheme: "file", Path: raw, local: true}, nil
+}
+
+// IsHTTP reports whether the remote is accessed via HTTP(S), i.e. whether
+// token based authentication applies.
+func (r *RemoteURL) IsHTTP() bool {
+	return r.Scheme == "https" || r.Scheme == "http"
+}
+
+// IsSSH reports whether the remote is accessed via SSH.
+func (r *RemoteURL) IsSSH() bool {
+	return r.Scheme == "ssh" || r.Scheme == "git+ssh" || r.Scheme == "ssh+git"
+}
+
+// String returns the URL in the same syntax it was parsed from.
+func (r *RemoteURL) String() string {
+	if r.scpLike {
+		host := r.Host
+		if r.User != nil {
+			host = r.User.Username() + "@" + host
+		}
+		return host + ":" + r.Path
+	}
+	if r.local {
+		return r.Path
+	}
+	u := &url.URL{
+		Scheme: r.Scheme,
+		User:   r.User,
+		Host:   r.Host,
+		Path:   r.Path,
+	}
+	return u.String()
+}
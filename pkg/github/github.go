@@ -16,6 +16,9 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -41,6 +44,8 @@ type Release struct {
 type Asset struct {
 	Path string
 	URL  string
+	// ID identifies an uploaded asset for DownloadReleaseAsset.
+	ID int64
 }
 
 // Client is the client for interacting with the GitHub API
@@ -52,16 +57,17 @@ type Client struct {
 
 // NewClient creates and initializes a new GitHubClient
 func NewClient(owner, repo, token, baseURL, uploadURL string) *Client {
-	var client *github.Client
+	httpClient := &http.Client{}
 	if token != "" {
 		ts := oauth2.StaticTokenSource(&oauth2.Token{
 			AccessToken: token,
 		})
-		tc := oauth2.NewClient(context.TODO(), ts)
-		client = github.NewClient(tc)
-	} else {
-		client = github.NewClient(nil)
+		httpClient = oauth2.NewClient(context.TODO(), ts)
 	}
+	// Wait for rate limits to reset and retry transient failures instead of
+	// failing the whole run.
+	httpClient.Transport = newRateLimitTransport(httpClient.Transport)
+	client := github.NewClient(httpClient)
 
 	if baseEndpoint, err := url.Parse(baseURL); err == nil {
 		if !strings.HasSuffix(baseEndpoint.Path, "/") {
@@ -96,7 +102,7 @@ func (c *Client) GetRelease(ctx context.Context, tag string) (*Release, error) {
 		Assets: []*Asset{},
 	}
 	for _, ass := range release.Assets {
-		asset := &Asset{*ass.Name, *ass.BrowserDownloadURL}
+		asset := &Asset{Path: *ass.Name, URL: *ass.BrowserDownloadURL, ID: ass.GetID()}
 		result.Assets = append(result.Assets, asset)
 	}
 	return result, nil
@@ -128,7 +134,7 @@ func (c *Client) GetReleases(ctx context.Context) ([]*Release, error) {
 			Assets: []*Asset{},
 		}
 		for _, ass := range release.Assets {
-			asset := &Asset{*ass.Name, *ass.BrowserDownloadURL}
+			asset := &Asset{Path: *ass.Name, URL: *ass.BrowserDownloadURL, ID: ass.GetID()}
 			resultRel.Assets = append(resultRel.Assets, asset)
 		}
 		result = append(result, resultRel)
@@ -136,6 +142,18 @@ func (c *Client) GetReleases(ctx context.Context) ([]*Release, error) {
 	return result, nil
 }
 
+// DownloadReleaseAsset downloads the release asset with the given ID through
+// the API, which unlike the browser download URL of the asset is authenticated
+// and so also works for private repositories.
+func (c *Client) DownloadReleaseAsset(ctx context.Context, id int64) (io.ReadCloser, error) {
+	// The API redirects to a pre-signed URL, which needs no authentication.
+	rc, _, err := c.Repositories.DownloadReleaseAsset(ctx, c.owner, c.repo, id, http.DefaultClient)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading release asset %d", id)
+	}
+	return rc, nil
+}
+
 // CreateRelease creates a new release object in the GitHub API
 func (c *Client) CreateRelease(ctx context.Context, input *Release) error {
 	req := &github.RepositoryRelease{
@@ -153,38 +171,167 @@ func (c *Client) CreateRelease(ctx context.Context, input *Release) error {
 	}
 
 	for _, asset := range input.Assets {
-		if err := c.uploadReleaseAsset(*release.ID, asset.Path); err != nil {
+		if err := c.uploadReleaseAsset(ctx, *release.ID, asset.Path); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// CreatePullRequest creates a pull request in the repository specified by repoURL.
-// The return value is the pull request URL.
-func (c *Client) CreatePullRequest(owner string, repo string, message string, head string, base string) (string, error) {
-	split := strings.SplitN(message, "\n", 2)
-	title := split[0]
+// PullRequest is a pull request created by chart-releaser
+type PullRequest struct {
+	Number int
+	URL    string
+	NodeID string
+}
+
+// PullRequestOptions are applied to a pull request after it has been created
+// or updated
+type PullRequestOptions struct {
+	Labels        []string
+	Reviewers     []string
+	TeamReviewers []string
+	AutoMerge     bool
+	// MergeMethod is used for auto-merge: merge, squash or rebase
+	MergeMethod string
+}
+
+// GetPullRequest returns the open pull request from the head branch into the
+// base branch, or nil if there is none.
+func (c *Client) GetPullRequest(ctx context.Context, owner string, repo string, head string, base string) (*PullRequest, error) {
+	opts := &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", owner, head),
+		Base:  base,
+	}
+	pullRequests, _, err := c.PullRequests.List(ctx, owner, repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(pullRequests) == 0 {
+		return nil, nil
+	}
+	return newPullRequest(pullRequests[0]), nil
+}
 
+// CreatePullRequest creates a pull request in the repository specified by repoURL.
+// The first line of the message is used as title, the rest as body.
+func (c *Client) CreatePullRequest(ctx context.Context, owner string, repo string, message string, head string, base string) (*PullRequest, error) {
+	title, body := splitMessage(message)
 	pr := &github.NewPullRequest{
 		Title: &title,
 		Head:  &head,
 		Base:  &base,
 	}
-	if len(split) == 2 {
-		body := strings.TrimSpace(split[1])
+	if body != "" {
 		pr.Body = &body
 	}
 
-	pullRequest, _, err := c.PullRequests.Create(context.Background(), owner, repo, pr)
+	pullRequest, _, err := c.PullRequests.Create(ctx, owner, repo, pr)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return newPullRequest(pullRequest), nil
+}
+
+// UpdatePullRequest replaces the title and body of a pull request with the given message.
+func (c *Client) UpdatePullRequest(ctx context.Context, owner string, repo string, number int, message string) error {
+	title, body := splitMessage(message)
+	_, _, err := c.PullRequests.Edit(ctx, owner, repo, number, &github.PullRequest{
+		Title: &title,
+		Body:  &body,
+	})
+	return err
+}
+
+// ConfigurePullRequest adds labels and reviewers to a pull request and enables auto-merge.
+func (c *Client) ConfigurePullRequest(ctx context.Context, owner string, repo string, pr *PullRequest, opts *PullRequestOptions) error {
+	if len(opts.Labels) > 0 {
+		if _, _, err := c.Issues.AddLabelsToIssue(ctx, owner, repo, pr.Number, opts.Labels); err != nil {
+			return errors.Wrap(err, "failed to add labels")
+		}
+	}
+	if len(opts.Reviewers) > 0 || len(opts.TeamReviewers) > 0 {
+		reviewers := github.ReviewersRequest{
+			Reviewers:     opts.Reviewers,
+			TeamReviewers: opts.TeamReviewers,
+		}
+		if _, _, err := c.PullRequests.RequestReviewers(ctx, owner, repo, pr.Number, reviewers); err != nil {
+			return errors.Wrap(err, "failed to request reviewers")
+		}
+	}
+	if opts.AutoMerge {
+		if err := c.enableAutoMerge(ctx, pr.NodeID, opts.MergeMethod); err != nil {
+			return errors.Wrap(err, "failed to enable auto-merge")
+		}
+	}
+	return nil
+}
+
+// enableAutoMerge enables auto-merge for a pull request. It is only available
+// through the GraphQL API.
+func (c *Client) enableAutoMerge(ctx context.Context, nodeID string, mergeMethod string) error {
+	if mergeMethod == "" {
+		mergeMethod = "merge"
+	}
+	query := map[string]interface{}{
+		"query": `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) { clientMutationId }
+}`,
+		"variables": map[string]interface{}{
+			"id":     nodeID,
+			"method": strings.ToUpper(mergeMethod),
+		},
+	}
+
+	req, err := c.NewRequest(http.MethodPost, c.graphQLURL(), query)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := c.Do(ctx, req, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return errors.New(result.Errors[0].Message)
+	}
+	return nil
+}
+
+// graphQLURL returns the GraphQL endpoint. On GitHub Enterprise Server the REST
+// API lives under /api/v3/ and GraphQL under /api/graphql.
+func (c *Client) graphQLURL() string {
+	graphQLURL := *c.BaseURL
+	if strings.HasSuffix(graphQLURL.Path, "/api/v3/") {
+		graphQLURL.Path = strings.TrimSuffix(graphQLURL.Path, "v3/") + "graphql"
+	} else {
+		graphQLURL.Path += "graphql"
+	}
+	return graphQLURL.String()
+}
+
+func newPullRequest(pullRequest *github.PullRequest) *PullRequest {
+	return &PullRequest{
+		Number: pullRequest.GetNumber(),
+		URL:    pullRequest.GetHTMLURL(),
+		NodeID: pullRequest.GetNodeID(),
+	}
+}
+
+func splitMessage(message string) (string, string) {
+	split := strings.SplitN(message, "\n", 2)
+	if len(split) == 2 {
+		return split[0], strings.TrimSpace(split[1])
 	}
-	return *pullRequest.HTMLURL, nil
+	return split[0], ""
 }
 
 // UploadAsset uploads specified assets to a given release object
-func (c *Client) uploadReleaseAsset(releaseID int64, filename string) error {
+func (c *Client) uploadReleaseAsset(ctx context.Context, releaseID int64, filename string) error {
 	filename, err := filepath.Abs(filename)
 	if err != nil {
 		return errors.Wrap(err, "failed to get abs path")
@@ -195,13 +342,13 @@ func (c *Client) uploadReleaseAsset(releaseID int64, filename string) error {
 		Name: filepath.Base(filename),
 	}
 
-	return retry.Retry(3, 3*time.Second, func() error {
+	return retry.WithContext(ctx, 3, 3*time.Second, func() error {
 		f, err := os.Open(filename)
 		if err != nil {
 			return errors.Wrap(err, "failed to open file")
 		}
 		defer f.Close()
-		if _, _, err = c.Repositories.UploadReleaseAsset(context.TODO(), c.owner, c.repo, releaseID, opts, f); err != nil {
+		if _, _, err = c.Repositories.UploadReleaseAsset(ctx, c.owner, c.repo, releaseID, opts, f); err != nil {
 			return errors.Wrapf(err, "failed to upload release asset: %s", filename)
 		}
 		return nil
@@ -0,0 +1,144 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_graphQLURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		expected string
+	}{
+		{
+			name:     "github.com",
+			baseURL:  "https://api.github.com/",
+			expected: "https://api.github.com/graphql",
+		},
+		{
+			name:     "github-enterprise-server",
+			baseURL:  "https://github.example.com/api/v3/",
+			expected: "https://github.example.com/api/graphql",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("owner", "repo", "", tt.baseURL, tt.baseURL)
+			assert.Equal(t, tt.expected, c.graphQLURL())
+		})
+	}
+}
+
+func TestClient_PullRequests(t *testing.T) {
+	var requests []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		fmt.Fprint(w, `[{"number": 7, "html_url": "https://github.com/owner/repo/pull/7", "node_id": "PR_7"}]`)
+	})
+	mux.HandleFunc("/repos/owner/repo/issues/7/labels", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		var labels []string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&labels))
+		assert.Equal(t, []string{"charts"}, labels)
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/repos/owner/repo/pulls/7/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		var query struct {
+			Variables map[string]string `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+		assert.Equal(t, map[string]string{"id": "PR_7", "method": "SQUASH"}, query.Variables)
+		fmt.Fprint(w, `{"data": {}}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c := NewClient("owner", "repo", "", server.URL, server.URL)
+	ctx := context.Background()
+
+	pr, err := c.GetPullRequest(ctx, "owner", "repo", "chart-releaser-index", "gh-pages")
+	require.NoError(t, err)
+	assert.Equal(t, &PullRequest{Number: 7, URL: "https://github.com/owner/repo/pull/7", NodeID: "PR_7"}, pr)
+
+	err = c.ConfigurePullRequest(ctx, "owner", "repo", pr, &PullRequestOptions{
+		Labels:      []string{"charts"},
+		Reviewers:   []string{"octocat"},
+		AutoMerge:   true,
+		MergeMethod: "squash",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"GET /repos/owner/repo/pulls?base=gh-pages&head=owner%3Achart-releaser-index&state=open",
+		"POST /repos/owner/repo/issues/7/labels",
+		"POST /repos/owner/repo/pulls/7/requested_reviewers",
+		"POST /graphql",
+	}, requests)
+}
+
+func TestClient_enableAutoMergeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errors": [{"message": "Auto merge is not allowed for this repository"}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	c := NewClient("owner", "repo", "", server.URL, server.URL)
+	err := c.enableAutoMerge(context.Background(), "PR_7", "")
+	require.EqualError(t, err, "Auto merge is not allowed for this repository")
+}
+
+func TestClient_DownloadReleaseAsset(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/releases/assets/42", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		http.Redirect(w, r, "/storage/app-1.0.0.tgz?signature=abc", http.StatusFound)
+	})
+	mux.HandleFunc("/storage/app-1.0.0.tgz", func(w http.ResponseWriter, r *http.Request) {
+		// Pre-signed URLs must not get the token.
+		assert.Empty(t, r.Header.Get("Authorization"))
+		fmt.Fprint(w, "package")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c := NewClient("owner", "repo", "secret", server.URL, server.URL)
+	rc, err := c.DownloadReleaseAsset(context.Background(), 42)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "package", string(data))
+
+	_, err = c.DownloadReleaseAsset(context.Background(), 7)
+	require.Error(t, err)
+}
@@ -0,0 +1,258 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+	headerRetryAfter    = "Retry-After"
+
+	defaultMaxRetries = 5
+	defaultBaseDelay  = time.Second
+	defaultMaxDelay   = 30 * time.Second
+)
+
+// rateLimitTransport is an http.RoundTripper that honors the GitHub API rate
+// limits. Requests rejected because of a primary or secondary rate limit are
+// retried once the limit has reset, and idempotent requests failing with a
+// server error are retried with jittered exponential backoff.
+type rateLimitTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	now        func() time.Time
+	sleep      func(ctx context.Context, d time.Duration) error
+
+	mu     sync.Mutex
+	logged bool
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{
+		base:       base,
+		maxRetries: defaultMaxRetries,
+		baseDelay:  defaultBaseDelay,
+		maxDelay:   defaultMaxDelay,
+		now:        time.Now,
+		sleep:      sleepContext,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			var err error
+			if req, err = rewindRequest(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			if attempt >= t.maxRetries || !isIdempotent(req.Method) || !canRewind(req) || ctx.Err() != nil {
+				return nil, err
+			}
+			delay := t.backoff(attempt)
+			fmt.Printf("GitHub API request %s %s failed (%s), retrying in %s\n", req.Method, req.URL.Path, err, delay)
+			if err := t.sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		t.logRate(resp)
+
+		delay, retry := t.retryDelay(req, resp, attempt)
+		if !retry || attempt >= t.maxRetries || !canRewind(req) {
+			// The request went through but used up the remaining budget. Wait for
+			// the reset before handing the response back, otherwise go-github
+			// fails any subsequent call without even sending it.
+			if resp.StatusCode < http.StatusBadRequest && resp.Header.Get(headerRateRemaining) == "0" {
+				if wait := t.untilReset(resp); wait > 0 {
+					fmt.Printf("GitHub API rate limit exhausted, waiting %s for it to reset\n", wait)
+					if err := t.sleep(ctx, wait); err != nil {
+						resp.Body.Close()
+						return nil, err
+					}
+				}
+			}
+			return resp, nil
+		}
+
+		// Drain the body so the connection can be reused.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		fmt.Printf("GitHub API request %s %s returned %s, retrying in %s\n", req.Method, req.URL.Path, resp.Status, delay)
+		if err := t.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay reports whether the request should be retried for the given
+// response, and how long to wait before doing so.
+func (t *rateLimitTransport) retryDelay(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		// Secondary rate limits tell us how long to back off. The request was
+		// rejected before being processed, so it is safe to retry any method.
+		if wait, ok := t.retryAfter(resp); ok {
+			return wait, true
+		}
+		// Primary rate limit exhausted.
+		if resp.Header.Get(headerRateRemaining) == "0" {
+			return t.untilReset(resp), true
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return t.backoff(attempt), true
+		}
+		return 0, false
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return t.backoff(attempt), isIdempotent(req.Method)
+	default:
+		return 0, false
+	}
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func (t *rateLimitTransport) retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get(headerRetryAfter)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(t.now()); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// untilReset returns the time left until the rate limit window resets. A
+// second is added to account for clock skew between us and GitHub.
+func (t *rateLimitTransport) untilReset(resp *http.Response) time.Duration {
+	reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64)
+	if err != nil {
+		return t.baseDelay
+	}
+	wait := time.Unix(reset, 0).Sub(t.now()) + time.Second
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// backoff returns an exponentially growing delay for the given attempt with
+// jitter applied, so that concurrent clients do not retry in lockstep.
+func (t *rateLimitTransport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << uint(attempt)
+	if delay <= 0 || delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1)) // nolint: gosec
+}
+
+// logRate prints the remaining rate limit budget. It is printed for the first
+// response and for every response once less than a tenth of it is left.
+func (t *rateLimitTransport) logRate(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get(headerRateLimit))
+	if err != nil || limit == 0 {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get(headerRateRemaining))
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	first := !t.logged
+	t.logged = true
+	t.mu.Unlock()
+
+	if !first && remaining > limit/10 {
+		return
+	}
+
+	resetAt := "unknown"
+	if reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64); err == nil {
+		resetAt = time.Unix(reset, 0).Format(time.RFC3339)
+	}
+	fmt.Printf("GitHub API rate limit: %d of %d requests remaining, resets at %s\n", remaining, limit, resetAt)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// canRewind reports whether the request body can be sent again.
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
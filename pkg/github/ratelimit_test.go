@@ -0,0 +1,217 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitTransport_RoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	reset := strconv.FormatInt(now.Add(10*time.Second).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		method    string
+		responses []func(w http.ResponseWriter)
+		status    int
+		requests  int
+		waits     []time.Duration
+	}{
+		{
+			name:   "primary-rate-limit",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set(headerRateLimit, "5000")
+					w.Header().Set(headerRateRemaining, "0")
+					w.Header().Set(headerRateReset, reset)
+					w.WriteHeader(http.StatusForbidden)
+				},
+				func(w http.ResponseWriter) {
+					w.Header().Set(headerRateLimit, "5000")
+					w.Header().Set(headerRateRemaining, "4999")
+					w.WriteHeader(http.StatusOK)
+				},
+			},
+			status:   http.StatusOK,
+			requests: 2,
+			waits:    []time.Duration{11 * time.Second},
+		},
+		{
+			name:   "secondary-rate-limit-post",
+			method: http.MethodPost,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set(headerRetryAfter, "60")
+					w.WriteHeader(http.StatusForbidden)
+				},
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusCreated)
+				},
+			},
+			status:   http.StatusCreated,
+			requests: 2,
+			waits:    []time.Duration{time.Minute},
+		},
+		{
+			name:   "server-error-get",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusBadGateway)
+				},
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusOK)
+				},
+			},
+			status:   http.StatusOK,
+			requests: 2,
+			waits:    []time.Duration{-1},
+		},
+		{
+			name:   "server-error-post",
+			method: http.MethodPost,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusBadGateway)
+				},
+			},
+			status:   http.StatusBadGateway,
+			requests: 1,
+		},
+		{
+			name:   "forbidden",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set(headerRateRemaining, "4000")
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			status:   http.StatusForbidden,
+			requests: 1,
+		},
+		{
+			name:   "budget-exhausted",
+			method: http.MethodGet,
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set(headerRateLimit, "5000")
+					w.Header().Set(headerRateRemaining, "0")
+					w.Header().Set(headerRateReset, reset)
+					w.WriteHeader(http.StatusOK)
+				},
+			},
+			status:   http.StatusOK,
+			requests: 1,
+			waits:    []time.Duration{11 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method == http.MethodPost {
+					assert.Equal(t, "payload", string(body))
+				}
+				tt.responses[requests](w)
+				requests++
+			}))
+			t.Cleanup(server.Close)
+
+			var waits []time.Duration
+			transport := newRateLimitTransport(http.DefaultTransport)
+			transport.now = func() time.Time { return now }
+			transport.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("payload"))
+			require.NoError(t, err)
+			resp, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, tt.requests, requests)
+			require.Len(t, waits, len(tt.waits))
+			for i, wait := range tt.waits {
+				if wait < 0 {
+					// jittered backoff
+					assert.True(t, waits[i] >= defaultBaseDelay/2 && waits[i] <= defaultBaseDelay)
+				} else {
+					assert.Equal(t, wait, waits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitTransport_GivesUp(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	transport := newRateLimitTransport(http.DefaultTransport)
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		assert.LessOrEqual(t, d, defaultMaxDelay)
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, defaultMaxRetries+1, requests)
+}
+
+func TestRateLimitTransport_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRetryAfter, "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := newRateLimitTransport(http.DefaultTransport)
+	transport.sleep = func(_ context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(req)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,287 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// Severity levels of checks, as configured.
+const (
+	LevelOff     = "off"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// apiDeprecation is a deprecated Kubernetes API version of a kind. Versions
+// are Kubernetes minor versions; Removed is empty if the API is still served.
+type apiDeprecation struct {
+	APIVersion  string
+	Kind        string
+	Deprecated  string
+	Removed     string
+	Replacement string
+}
+
+// apiDeprecations is taken from
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/.
+var apiDeprecations = []apiDeprecation{
+	{"extensions/v1beta1", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", "1.9", "1.16", "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", "1.10", "1.16", "policy/v1beta1"},
+	{"extensions/v1beta1", "Ingress", "1.14", "1.22", "networking.k8s.io/v1"},
+	{"apps/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"authentication.k8s.io/v1beta1", "TokenReview", "1.19", "1.22", "authentication.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "LocalSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SelfSubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SubjectAccessReview", "1.19", "1.22", "authorization.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", "1.19", "1.22", "coordination.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", "1.17", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", "1.21", "1.25", "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "1.22", "1.25", "autoscaling/v2"},
+	{"policy/v1beta1", "PodDisruptionBudget", "1.21", "1.25", "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", "1.21", "1.25", ""},
+	{"node.k8s.io/v1beta1", "RuntimeClass", "1.20", "1.25", "node.k8s.io/v1"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.23", "1.26", "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", "1.24", "1.27", "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// maxKubeMinorVersion and maxKubePatchVersion bound the Kubernetes versions a
+// kubeVersion constraint is checked against. Constraints usually have no upper
+// bound.
+const (
+	maxKubeMinorVersion = 99
+	maxKubePatchVersion = 999
+)
+
+// APIFinding is a deprecated or removed API used by a chart.
+type APIFinding struct {
+	Chart      string `json:"chart"`
+	Template   string `json:"template"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Level      string `json:"level"`
+	Message    string `json:"message"`
+}
+
+func (f *APIFinding) String() string {
+	level := "[WARNING]"
+	if f.Level == LevelError {
+		level = "[ERROR]"
+	}
+	return fmt.Sprintf("%s %s: %s", level, f.Template, f.Message)
+}
+
+// kubeTarget is the set of Kubernetes versions a chart is checked for: the
+// configured version, else the chart's kubeVersion constraint, else all.
+type kubeTarget struct {
+	version    *semver.Version
+	constraint *semver.Constraints
+}
+
+// includesFrom reports whether the target includes the given Kubernetes minor
+// version or a later one.
+func (t *kubeTarget) includesFrom(version string) bool {
+	v := semver.MustParse(version)
+	switch {
+	case t.version != nil:
+		return t.version.Major() > v.Major() || t.version.Major() == v.Major() && t.version.Minor() >= v.Minor()
+	case t.constraint != nil:
+		// A minor version is included if its first or last patch version
+		// is, e.g. 1.22 by '>=1.22.3' and by '<1.22.3'.
+		for minor := v.Minor(); minor <= maxKubeMinorVersion; minor++ {
+			for _, patch := range []uint64{0, maxKubePatchVersion} {
+				if t.constraint.Check(semver.New(v.Major(), minor, patch, "", "")) {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// CheckAPIs renders the chart in the given path with its default values and
+// every ci/*-values.yaml file and returns the deprecated and removed
+// Kubernetes APIs used in the manifests, at the levels configured with
+// DeprecatedAPIs and RemovedAPIs.
+func CheckAPIs(config *config.Options, chartPath string) ([]*APIFinding, error) {
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading chart %s", chartPath)
+	}
+	target := &kubeTarget{}
+	if config.KubeVersion != "" {
+		if target.version, err = semver.NewVersion(config.KubeVersion); err != nil {
+			return nil, errors.Wrapf(err, "invalid Kubernetes version %q", config.KubeVersion)
+		}
+	} else if ch.Metadata.KubeVersion != "" {
+		if target.constraint, err = semver.NewConstraint(ch.Metadata.KubeVersion); err != nil {
+			return nil, errors.Wrapf(err, "invalid kubeVersion %q in chart %s", ch.Metadata.KubeVersion, chartPath)
+		}
+	}
+	deprecatedLevel := checkLevel(config.DeprecatedAPIs, LevelWarning)
+	removedLevel := checkLevel(config.RemovedAPIs, LevelError)
+
+	valuesFiles, err := filepath.Glob(filepath.Join(chartPath, "ci", "*-values.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var findings []*APIFinding
+	seen := map[string]bool{}
+	for _, valuesFile := range append([]string{""}, valuesFiles...) {
+		vals := map[string]interface{}{}
+		if valuesFile != "" {
+			values, err := chartutil.ReadValuesFile(valuesFile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading values file %s", valuesFile)
+			}
+			vals = values.AsMap()
+		}
+		manifests, err := renderChart(chartPath, vals)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error rendering chart %s", chartPath)
+		}
+
+		for name, manifest := range manifests {
+			if filepath.Ext(name) == ".txt" {
+				continue
+			}
+			for _, doc := range releaseutil.SplitManifests(manifest) {
+				var head releaseutil.SimpleHead
+				if err := yaml.Unmarshal([]byte(doc), &head); err != nil {
+					return nil, errors.Wrapf(err, "YAML parse error on %s", name)
+				}
+				deprecation := findDeprecation(head.Version, head.Kind)
+				if deprecation == nil {
+					continue
+				}
+				finding := &APIFinding{Chart: chartPath, Template: name, APIVersion: head.Version, Kind: head.Kind}
+				switch {
+				case deprecation.Removed != "" && target.includesFrom(deprecation.Removed):
+					finding.Level = removedLevel
+					finding.Message = fmt.Sprintf("%s %s was removed in Kubernetes %s", head.Version, head.Kind, deprecation.Removed)
+				case target.includesFrom(deprecation.Deprecated):
+					finding.Level = deprecatedLevel
+					finding.Message = fmt.Sprintf("%s %s is deprecated since Kubernetes %s", head.Version, head.Kind, deprecation.Deprecated)
+					if deprecation.Removed != "" {
+						finding.Message += fmt.Sprintf(" and removed in %s", deprecation.Removed)
+					}
+				default:
+					continue
+				}
+				if deprecation.Replacement != "" {
+					finding.Message += ", use " + deprecation.Replacement
+				} else {
+					finding.Message += ", it has no replacement"
+				}
+
+				key := finding.Template + "\x00" + finding.APIVersion + "\x00" + finding.Kind
+				if finding.Level == LevelOff || seen[key] {
+					continue
+				}
+				seen[key] = true
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Template < findings[j].Template
+	})
+	return findings, nil
+}
+
+// checkAPIs checks the chart in the given path for deprecated and removed
+// APIs and writes the findings to out. An error is returned for findings at
+// error level.
+func checkAPIs(out io.Writer, config *config.Options, chartPath string) error {
+	fmt.Fprintf(out, "==> Checking Kubernetes APIs of %s\n", chartPath)
+	findings, err := CheckAPIs(config, chartPath)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, finding := range findings {
+		fmt.Fprintln(out, finding)
+		if finding.Level == LevelError {
+			failed = true
+		}
+	}
+	if failed {
+		return errors.Errorf("chart %s uses removed or deprecated Kubernetes APIs", chartPath)
+	}
+	return nil
+}
+
+func findDeprecation(apiVersion string, kind string) *apiDeprecation {
+	for i := range apiDeprecations {
+		if apiDeprecations[i].APIVersion == apiVersion && apiDeprecations[i].Kind == kind {
+			return &apiDeprecations[i]
+		}
+	}
+	return nil
+}
+
+// checkLevel returns the configured level, or the default if none is set.
+func checkLevel(level string, defaultLevel string) string {
+	if level == "" {
+		return defaultLevel
+	}
+	return level
+}
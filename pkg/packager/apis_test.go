@@ -0,0 +1,191 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+func TestCheckAPIs(t *testing.T) {
+	tests := []struct {
+		name     string
+		chart    string
+		options  *config.Options
+		expected []string
+		error    string
+	}{
+		{
+			name:    "all-versions",
+			chart:   "testdata/apis/legacy",
+			options: &config.Options{},
+			expected: []string{
+				"[ERROR] legacy/templates/hpa.yaml: autoscaling/v2beta2 HorizontalPodAutoscaler was removed in Kubernetes 1.26, use autoscaling/v2",
+				"[ERROR] legacy/templates/ingress.yaml: extensions/v1beta1 Ingress was removed in Kubernetes 1.22, use networking.k8s.io/v1",
+				"[ERROR] legacy/templates/workloads.yaml: batch/v1beta1 CronJob was removed in Kubernetes 1.25, use batch/v1",
+			},
+		},
+		{
+			name:    "kube-version",
+			chart:   "testdata/apis/legacy",
+			options: &config.Options{KubeVersion: "v1.23.4"},
+			expected: []string{
+				"[WARNING] legacy/templates/hpa.yaml: autoscaling/v2beta2 HorizontalPodAutoscaler is deprecated since Kubernetes 1.23 and removed in 1.26, use autoscaling/v2",
+				"[ERROR] legacy/templates/ingress.yaml: extensions/v1beta1 Ingress was removed in Kubernetes 1.22, use networking.k8s.io/v1",
+				"[WARNING] legacy/templates/workloads.yaml: batch/v1beta1 CronJob is deprecated since Kubernetes 1.21 and removed in 1.25, use batch/v1",
+			},
+		},
+		{
+			name:    "levels",
+			chart:   "testdata/apis/legacy",
+			options: &config.Options{KubeVersion: "1.23", DeprecatedAPIs: "error", RemovedAPIs: "off"},
+			expected: []string{
+				"[ERROR] legacy/templates/hpa.yaml: autoscaling/v2beta2 HorizontalPodAutoscaler is deprecated since Kubernetes 1.23 and removed in 1.26, use autoscaling/v2",
+				"[ERROR] legacy/templates/workloads.yaml: batch/v1beta1 CronJob is deprecated since Kubernetes 1.21 and removed in 1.25, use batch/v1",
+			},
+		},
+		{
+			name:    "chart-kube-version",
+			chart:   "testdata/apis/constrained",
+			options: &config.Options{},
+			expected: []string{
+				"[WARNING] constrained/templates/ingress.yaml: networking.k8s.io/v1beta1 Ingress is deprecated since Kubernetes 1.19 and removed in 1.22, use networking.k8s.io/v1",
+			},
+		},
+		{
+			name:    "flag-overrides-chart-kube-version",
+			chart:   "testdata/apis/constrained",
+			options: &config.Options{KubeVersion: "1.22"},
+			expected: []string{
+				"[ERROR] constrained/templates/ingress.yaml: networking.k8s.io/v1beta1 Ingress was removed in Kubernetes 1.22, use networking.k8s.io/v1",
+			},
+		},
+		{
+			name:    "current-apis",
+			chart:   "testdata/apis/current",
+			options: &config.Options{},
+		},
+		{
+			name:    "invalid-kube-version",
+			chart:   "testdata/apis/current",
+			options: &config.Options{KubeVersion: "latest"},
+			error:   `invalid Kubernetes version "latest"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := CheckAPIs(tt.options, tt.chart)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+			var actual []string
+			for _, finding := range findings {
+				assert.Equal(t, tt.chart, finding.Chart)
+				actual = append(actual, finding.String())
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestKubeTarget_includesFrom(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{">=1.19.0-0 <1.22.0-0", "1.19", true},
+		{">=1.19.0-0 <1.22.0-0", "1.22", false},
+		{">=1.22.3", "1.22", true},
+		{">=1.22.3", "1.25", true},
+		{">=1.22.3 <1.23.0", "1.22", true},
+		{"<1.22.3", "1.22", true},
+		{"<1.22.3", "1.23", false},
+		{"~1.21.2", "1.21", true},
+		{"~1.21.2", "1.22", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+"-"+tt.version, func(t *testing.T) {
+			constraint, err := semver.NewConstraint(tt.constraint)
+			require.NoError(t, err)
+			target := &kubeTarget{constraint: constraint}
+			assert.Equal(t, tt.expected, target.includesFrom(tt.version))
+		})
+	}
+}
+
+func TestPackager_CreatePackagesCheckAPIs(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   string
+		options *config.Options
+		error   string
+	}{
+		{
+			name:    "removed-api",
+			chart:   "legacy",
+			options: &config.Options{},
+			error:   "uses removed or deprecated Kubernetes APIs",
+		},
+		{
+			name:    "removed-api-as-warning",
+			chart:   "legacy",
+			options: &config.Options{RemovedAPIs: "warning"},
+		},
+		{
+			name:    "deprecated-api",
+			chart:   "constrained",
+			options: &config.Options{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.PackagePath = t.TempDir()
+			tt.options.CheckAPIs = true
+			chartPath := filepath.Join("testdata/apis", tt.chart)
+			p := NewPackager(tt.options, []string{chartPath}, nil)
+			err := p.CreatePackages(context.Background())
+			packageFile := filepath.Join(tt.options.PackagePath, tt.chart+"-0.1.0.tgz")
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				assert.NoFileExists(t, packageFile)
+				return
+			}
+			require.NoError(t, err)
+			assert.FileExists(t, packageFile)
+		})
+	}
+}
+
+func TestCheckAPIsOutput(t *testing.T) {
+	var out bytes.Buffer
+	err := checkAPIs(&out, &config.Options{KubeVersion: "1.21"}, "testdata/apis/constrained")
+	require.NoError(t, err)
+	assert.Equal(t, "==> Checking Kubernetes APIs of testdata/apis/constrained\n"+
+		"[WARNING] constrained/templates/ingress.yaml: networking.k8s.io/v1beta1 Ingress is deprecated since Kubernetes 1.19 and removed in 1.22, use networking.k8s.io/v1\n",
+		out.String())
+}
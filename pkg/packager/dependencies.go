@@ -0,0 +1,102 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// verifyDependencies checks that the dependencies vendored in the charts/
+// directory of the chart in the given path are the ones locked in Chart.lock,
+// without downloading anything. All problems found are reported together.
+func verifyDependencies(chartPath string) error {
+	ch, err := loader.LoadDir(chartPath)
+	if err != nil {
+		return errors.Wrapf(err, "error loading chart %s", chartPath)
+	}
+	if len(ch.Metadata.Dependencies) == 0 {
+		return nil
+	}
+	if ch.Lock == nil {
+		return errors.Errorf("chart %s has dependencies but no lock file, run 'helm dependency update' to create it", chartPath)
+	}
+
+	var problems []string
+	if digest, err := hashDependencies(ch.Metadata.Dependencies, ch.Lock.Dependencies); err != nil {
+		return err
+	} else if digest != ch.Lock.Digest && ch.Metadata.APIVersion != chart.APIVersionV1 && !usesRepositoryAliases(ch.Metadata.Dependencies) {
+		// Helm hashes the dependencies after resolving repository aliases
+		// and used a different hash for v1 charts, so only compare the
+		// digest when it is comparable without the repository config.
+		problems = append(problems, "the lock file is out of sync with the dependencies in Chart.yaml")
+	}
+
+	vendored := map[string][]string{}
+	for _, dep := range ch.Dependencies() {
+		vendored[dep.Name()] = append(vendored[dep.Name()], dep.Metadata.Version)
+	}
+	for _, dep := range ch.Lock.Dependencies {
+		versions, ok := vendored[dep.Name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("dependency %s %s is missing in charts/", dep.Name, dep.Version))
+		case !contains(versions, dep.Version):
+			problems = append(problems, fmt.Sprintf("dependency %s in charts/ has version %s, but %s is locked", dep.Name, strings.Join(versions, ", "), dep.Version))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("vendored dependencies of chart %s do not match the lock file, run 'helm dependency build' to update them:\n  %s",
+			chartPath, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// hashDependencies computes the digest of the dependencies and the locked
+// dependencies the same way Helm does for Chart.lock.
+func hashDependencies(req, lock []*chart.Dependency) (string, error) {
+	data, err := json.Marshal([2][]*chart.Dependency{req, lock})
+	if err != nil {
+		return "", err
+	}
+	digest, err := provenance.Digest(bytes.NewBuffer(data))
+	return "sha256:" + digest, err
+}
+
+func usesRepositoryAliases(dependencies []*chart.Dependency) bool {
+	for _, dep := range dependencies {
+		if strings.HasPrefix(dep.Repository, "@") || strings.HasPrefix(dep.Repository, "alias:") {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
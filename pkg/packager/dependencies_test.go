@@ -0,0 +1,135 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// vendoredChart returns a copy of testdata/dependencies/sub with its file://
+// dependency vendored and locked.
+func vendoredChart(t *testing.T) string {
+	dir := t.TempDir()
+	copyDir(t, "testdata/dependencies/lib", filepath.Join(dir, "lib"))
+	copyDir(t, "testdata/dependencies/sub", filepath.Join(dir, "sub"))
+	chartPath := filepath.Join(dir, "sub")
+
+	manager := &downloader.Manager{
+		Out:              io.Discard,
+		ChartPath:        chartPath,
+		Getters:          getter.Providers{},
+		RepositoryConfig: filepath.Join(dir, "repositories.yaml"),
+		RepositoryCache:  filepath.Join(dir, "cache"),
+	}
+	require.NoError(t, manager.Update())
+	require.FileExists(t, filepath.Join(chartPath, "Chart.lock"))
+	require.FileExists(t, filepath.Join(chartPath, "charts", "lib-0.1.0.tgz"))
+	return chartPath
+}
+
+func TestVerifyDependencies(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, chartPath string)
+		error  string
+	}{
+		{
+			name: "vendored",
+		},
+		{
+			name: "missing",
+			modify: func(t *testing.T, chartPath string) {
+				require.NoError(t, os.Remove(filepath.Join(chartPath, "charts", "lib-0.1.0.tgz")))
+			},
+			error: "dependency lib 0.1.0 is missing in charts/",
+		},
+		{
+			name: "wrong-version",
+			modify: func(t *testing.T, chartPath string) {
+				replaceInFile(t, filepath.Join(chartPath, "Chart.lock"), "version: 0.1.0", "version: 0.2.0")
+			},
+			error: "dependency lib in charts/ has version 0.1.0, but 0.2.0 is locked",
+		},
+		{
+			name: "out-of-sync",
+			modify: func(t *testing.T, chartPath string) {
+				replaceInFile(t, filepath.Join(chartPath, "Chart.yaml"), "version: 0.1.0\n    repository", "version: ~0.1.0\n    repository")
+			},
+			error: "the lock file is out of sync with the dependencies in Chart.yaml",
+		},
+		{
+			name: "no-lock-file",
+			modify: func(t *testing.T, chartPath string) {
+				require.NoError(t, os.Remove(filepath.Join(chartPath, "Chart.lock")))
+			},
+			error: "has dependencies but no lock file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartPath := vendoredChart(t)
+			if tt.modify != nil {
+				tt.modify(t, chartPath)
+			}
+			err := verifyDependencies(chartPath)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyDependenciesWithoutDependencies(t *testing.T) {
+	require.NoError(t, verifyDependencies("testdata/test-chart"))
+}
+
+func TestPackager_CreatePackagesOffline(t *testing.T) {
+	chartPath := vendoredChart(t)
+	packagePath := t.TempDir()
+
+	p := NewPackager(&config.Options{PackagePath: packagePath, Offline: true}, []string{chartPath}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+	assert.FileExists(t, filepath.Join(packagePath, "sub-0.1.0.tgz"))
+
+	require.NoError(t, os.Remove(filepath.Join(chartPath, "charts", "lib-0.1.0.tgz")))
+	p = NewPackager(&config.Options{PackagePath: packagePath, SkipDependencyUpdate: true}, []string{chartPath}, nil)
+	err := p.CreatePackages(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency lib 0.1.0 is missing in charts/")
+	// The chart was not rebuilt behind our back.
+	assert.NoFileExists(t, filepath.Join(chartPath, "charts", "lib-0.1.0.tgz"))
+}
+
+func replaceInFile(t *testing.T, path string, old string, new string) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), old)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0644))
+}
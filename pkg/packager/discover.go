@@ -0,0 +1,91 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// Chart is a chart found in the charts directory.
+type Chart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// DiscoverCharts walks the charts directory and returns every chart in it, in
+// lexical order of their paths. Directories below a chart, such as vendored
+// subcharts, and hidden directories are not searched. Charts and directories
+// whose path relative to the charts directory matches one of the exclude
+// patterns are skipped, as are library charts if SkipLibraryCharts is set.
+func DiscoverCharts(config *config.Options) ([]*Chart, error) {
+	for _, pattern := range config.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid exclude pattern %q", pattern)
+		}
+	}
+
+	charts := []*Chart{}
+	err := filepath.WalkDir(config.ChartsDir, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(config.ChartsDir, dir)
+		if err != nil {
+			return err
+		}
+		if rel != "." && (strings.HasPrefix(entry.Name(), ".") || isExcluded(filepath.ToSlash(rel), config.Exclude)) {
+			return filepath.SkipDir
+		}
+
+		metadata, err := chartutil.LoadChartfile(filepath.Join(dir, chartutil.ChartfileName))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "error loading chart %s", dir)
+		}
+
+		if !config.SkipLibraryCharts || metadata.Type != "library" {
+			charts = append(charts, &Chart{Name: metadata.Name, Version: metadata.Version, Path: dir})
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error discovering charts")
+	}
+	return charts, nil
+}
+
+func isExcluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+	}
+	return false
+}
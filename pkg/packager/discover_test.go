@@ -0,0 +1,94 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+func TestDiscoverCharts(t *testing.T) {
+	web := &Chart{Name: "web", Version: "1.0.0", Path: "testdata/charts/apps/web"}
+	beta := &Chart{Name: "beta", Version: "0.0.1", Path: "testdata/charts/incubator/beta"}
+	common := &Chart{Name: "common", Version: "2.0.0", Path: "testdata/charts/libs/common"}
+
+	tests := []struct {
+		name     string
+		options  *config.Options
+		expected []*Chart
+		error    bool
+	}{
+		{
+			name:     "all-charts",
+			options:  &config.Options{ChartsDir: "testdata/charts"},
+			expected: []*Chart{web, beta, common},
+		},
+		{
+			name:     "skip-library-charts",
+			options:  &config.Options{ChartsDir: "testdata/charts", SkipLibraryCharts: true},
+			expected: []*Chart{web, beta},
+		},
+		{
+			name:     "exclude-directory",
+			options:  &config.Options{ChartsDir: "testdata/charts", Exclude: []string{"incubator"}},
+			expected: []*Chart{web, common},
+		},
+		{
+			name:     "exclude-glob",
+			options:  &config.Options{ChartsDir: "testdata/charts", Exclude: []string{"*/web", "libs/c*"}},
+			expected: []*Chart{beta},
+		},
+		{
+			name:     "charts-dir-is-chart",
+			options:  &config.Options{ChartsDir: "testdata/test-chart"},
+			expected: []*Chart{{Name: "test-chart", Version: "0.1.0", Path: "testdata/test-chart"}},
+		},
+		{
+			name:     "no-charts",
+			options:  &config.Options{ChartsDir: "testdata/charts/docs"},
+			expected: []*Chart{},
+		},
+		{
+			name:    "invalid-exclude-pattern",
+			options: &config.Options{ChartsDir: "testdata/charts", Exclude: []string{"["}},
+			error:   true,
+		},
+		{
+			name:    "invalid-chart",
+			options: &config.Options{ChartsDir: "testdata/invalid-charts"},
+			error:   true,
+		},
+		{
+			name:    "missing-charts-dir",
+			options: &config.Options{ChartsDir: "testdata/missing"},
+			error:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charts, err := DiscoverCharts(tt.options)
+			if tt.error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, charts)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"sigs.k8s.io/yaml"
+)
+
+// imagesAnnotation lists the container images of a chart on Artifact Hub, see
+// https://artifacthub.io/docs/topics/annotations/helm/.
+const imagesAnnotation = "artifacthub.io/images"
+
+// chartImages renders the chart package in the given path client-side, once
+// with its default values and once for every values file matching the
+// patterns relative to the chart source directory, and returns all container
+// images referenced in the manifests, sorted.
+func chartImages(chartPath string, packagePath string, valuesPatterns []string) ([]string, error) {
+	valuesFiles := []string{""}
+	for _, pattern := range valuesPatterns {
+		matches, err := filepath.Glob(filepath.Join(chartPath, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid values file pattern %q", pattern)
+		}
+		valuesFiles = append(valuesFiles, matches...)
+	}
+
+	images := map[string]bool{}
+	for _, valuesFile := range valuesFiles {
+		vals := map[string]interface{}{}
+		if valuesFile != "" {
+			values, err := chartutil.ReadValuesFile(valuesFile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading values file %s", valuesFile)
+			}
+			vals = values.AsMap()
+		}
+
+		manifests, err := renderChart(packagePath, vals)
+		if err != nil {
+			if valuesFile != "" {
+				return nil, errors.Wrapf(err, "error rendering chart %s with values %s", chartPath, valuesFile)
+			}
+			return nil, errors.Wrapf(err, "error rendering chart %s", chartPath)
+		}
+		for name, manifest := range manifests {
+			if strings.HasSuffix(name, "NOTES.txt") {
+				continue
+			}
+			for _, doc := range releaseutil.SplitManifests(manifest) {
+				var obj interface{}
+				if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+					return nil, errors.Wrapf(err, "error parsing rendered template %s", name)
+				}
+				collectImages(obj, images)
+			}
+		}
+	}
+
+	var sorted []string
+	for image := range images {
+		sorted = append(sorted, image)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// collectImages adds the values of all 'image' fields of the manifest to
+// images.
+func collectImages(obj interface{}, images map[string]bool) {
+	switch obj := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range obj {
+			if image, ok := value.(string); ok && key == "image" {
+				if image = strings.TrimSpace(image); image != "" {
+					images[image] = true
+				}
+				continue
+			}
+			collectImages(value, images)
+		}
+	case []interface{}:
+		for _, value := range obj {
+			collectImages(value, images)
+		}
+	}
+}
+
+// imageReference is a parsed container image reference.
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImage splits a container image reference into its parts. The registry
+// is empty for images on Docker Hub without an explicit registry.
+func parseImage(image string) imageReference {
+	var ref imageReference
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, ref.Digest = image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i >= 0 && !strings.Contains(image[i:], "/") {
+		image, ref.Tag = image[:i], image[i+1:]
+	}
+	if i := strings.Index(image, "/"); i >= 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry, image = host, image[i+1:]
+		}
+	}
+	ref.Repository = image
+	return ref
+}
+
+// Name returns the last path element of the repository.
+func (r imageReference) Name() string {
+	return path.Base(r.Repository)
+}
+
+// Version returns the digest, or else the tag.
+func (r imageReference) Version() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// PackageURL returns the package URL of the image, see
+// https://github.com/package-url/purl-spec.
+func (r imageReference) PackageURL() string {
+	purl := "pkg:docker/" + r.Repository
+	if version := r.Version(); version != "" {
+		purl += "@" + strings.ReplaceAll(version, ":", "%3A")
+	}
+	if r.Registry != "" {
+		purl += "?repository_url=" + r.Registry
+	}
+	return purl
+}
+
+// annotateImages adds the images annotation to the chart package in the given
+// path, unless the chart already has one.
+func annotateImages(packagePath string, images []string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	ch, err := loader.Load(packagePath)
+	if err != nil {
+		return err
+	}
+	if _, ok := ch.Metadata.Annotations[imagesAnnotation]; ok {
+		return nil
+	}
+
+	type annotatedImage struct {
+		Name  string `json:"name"`
+		Image string `json:"image"`
+	}
+	var annotated []annotatedImage
+	for _, image := range images {
+		annotated = append(annotated, annotatedImage{Name: parseImage(image).Name(), Image: image})
+	}
+	data, err := yaml.Marshal(annotated)
+	if err != nil {
+		return err
+	}
+	if ch.Metadata.Annotations == nil {
+		ch.Metadata.Annotations = map[string]string{}
+	}
+	ch.Metadata.Annotations[imagesAnnotation] = string(data)
+
+	_, err = chartutil.Save(ch, filepath.Dir(packagePath))
+	return err
+}
@@ -0,0 +1,72 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// lintChart runs Helm's linter on the chart in the given path, once with the
+// chart's default values and once for every ci/*-values.yaml file, like
+// chart-testing does. All findings are written to out. An error is returned if
+// any run found errors, or warnings if strict is set.
+func lintChart(out io.Writer, chartPath string, strict bool) error {
+	valuesFiles, err := filepath.Glob(filepath.Join(chartPath, "ci", "*-values.yaml"))
+	if err != nil {
+		return err
+	}
+
+	client := action.NewLint()
+	client.Strict = strict
+
+	failed := false
+	fmt.Fprintf(out, "==> Linting %s\n", chartPath)
+	for _, valuesFile := range append([]string{""}, valuesFiles...) {
+		vals := map[string]interface{}{}
+		if valuesFile != "" {
+			values, err := chartutil.ReadValuesFile(valuesFile)
+			if err != nil {
+				return errors.Wrapf(err, "error reading values file %s", valuesFile)
+			}
+			vals = values.AsMap()
+			fmt.Fprintf(out, "==> Linting %s with values %s\n", chartPath, filepath.Base(valuesFile))
+		}
+
+		result := client.Run([]string{chartPath}, vals)
+		for _, msg := range result.Messages {
+			fmt.Fprintln(out, msg)
+		}
+		if result.TotalChartsLinted == 0 {
+			// The chart could not be loaded, so there are no messages.
+			for _, err := range result.Errors {
+				fmt.Fprintf(out, "[ERROR] %s\n", err)
+			}
+		}
+		if len(result.Errors) > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		return errors.Errorf("chart %s failed linting", chartPath)
+	}
+	return nil
+}
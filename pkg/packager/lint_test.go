@@ -0,0 +1,87 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintChart(t *testing.T) {
+	tests := []struct {
+		name      string
+		chartPath string
+		strict    bool
+		output    []string
+		error     bool
+	}{
+		{
+			name:      "valid",
+			chartPath: "testdata/lint/valid",
+			output:    []string{"==> Linting testdata/lint/valid\n"},
+		},
+		{
+			name:      "ci-values",
+			chartPath: "testdata/lint/ci-values",
+			output: []string{
+				"==> Linting testdata/lint/ci-values with values broken-values.yaml\n[ERROR] templates/: template: ci-values/templates/configmap.yaml",
+				"can't evaluate field name",
+				"==> Linting testdata/lint/ci-values with values default-values.yaml\n",
+			},
+			error: true,
+		},
+		{
+			name:      "template-error",
+			chartPath: "testdata/lint/broken",
+			output:    []string{"[ERROR] templates/"},
+			error:     true,
+		},
+		{
+			name:      "warning",
+			chartPath: "testdata/lint/warning",
+			output:    []string{"[WARNING] templates/"},
+		},
+		{
+			name:      "warning-strict",
+			chartPath: "testdata/lint/warning",
+			strict:    true,
+			output:    []string{"[WARNING] templates/"},
+			error:     true,
+		},
+		{
+			name:      "invalid-chart-path",
+			chartPath: "testdata/invalid-chart",
+			output:    []string{"[ERROR] unable to check Chart.yaml file in chart"},
+			error:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := lintChart(&out, tt.chartPath, tt.strict)
+			if tt.error {
+				require.EqualError(t, err, "chart "+tt.chartPath+" failed linting")
+			} else {
+				require.NoError(t, err)
+			}
+			for _, output := range tt.output {
+				assert.Contains(t, out.String(), output)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest lists the chart packages created by 'cr package'. It is written as
+// YAML if the file name ends in .yaml or .yml, and as JSON otherwise.
+type Manifest struct {
+	Packages []*ManifestEntry `json:"packages"`
+}
+
+// ManifestEntry describes a chart package.
+type ManifestEntry struct {
+	// Path is the path of the package, relative to the manifest.
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion,omitempty"`
+	// Digest is the SHA-256 digest of the package, as in index.yaml.
+	Digest string `json:"digest"`
+	// Signed is true if a provenance file was written next to the package.
+	Signed bool `json:"signed"`
+	// Source is the path of the chart directory the package was created
+	// from, relative to the manifest.
+	Source string `json:"source"`
+}
+
+// newManifestEntry describes the chart package in the given path for a
+// manifest in manifestPath.
+func newManifestEntry(manifestPath string, packagePath string, source string) (*ManifestEntry, error) {
+	ch, err := loader.Load(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := provenance.DigestFile(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(packagePath + ".prov")
+	signed := err == nil
+
+	path, err := relativePath(filepath.Dir(manifestPath), packagePath)
+	if err != nil {
+		return nil, err
+	}
+	if source, err = relativePath(filepath.Dir(manifestPath), source); err != nil {
+		return nil, err
+	}
+	return &ManifestEntry{
+		Path:       path,
+		Name:       ch.Metadata.Name,
+		Version:    ch.Metadata.Version,
+		AppVersion: ch.Metadata.AppVersion,
+		Digest:     digest,
+		Signed:     signed,
+		Source:     source,
+	}, nil
+}
+
+// relativePath returns target relative to base, with forward slashes.
+func relativePath(base string, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absBase, absTarget)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// WriteManifest writes the manifest to the given path.
+func WriteManifest(path string, manifest *Manifest) error {
+	var data []byte
+	var err error
+	if isYAMLFile(path) {
+		data, err = yaml.Marshal(manifest)
+	} else {
+		data, err = json.MarshalIndent(manifest, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadManifest reads the manifest in the given path and returns the package
+// paths in it, resolved against the directory of the manifest. An error is
+// returned if a package does not match its digest.
+func ReadManifest(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading package manifest")
+	}
+	manifest := &Manifest{}
+	// YAML is a superset of JSON.
+	if err := yaml.UnmarshalStrict(data, manifest); err != nil {
+		return nil, errors.Wrapf(err, "error parsing package manifest %s", path)
+	}
+
+	var packages []string
+	for _, entry := range manifest.Packages {
+		packagePath := filepath.Join(filepath.Dir(path), filepath.FromSlash(entry.Path))
+		digest, err := provenance.DigestFile(packagePath)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Digest != "" && digest != entry.Digest {
+			return nil, errors.Errorf("package %s does not match its digest in manifest %s", packagePath, path)
+		}
+		packages = append(packages, packagePath)
+	}
+	return packages, nil
+}
+
+func isYAMLFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,152 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/provenance"
+	"sigs.k8s.io/yaml"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+func TestPackager_CreatePackagesManifest(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifest  string
+		unmarshal func([]byte, interface{}) error
+	}{
+		{
+			name:      "json",
+			manifest:  "manifest.json",
+			unmarshal: json.Unmarshal,
+		},
+		{
+			name:     "yaml",
+			manifest: "out/manifest.yaml",
+			unmarshal: func(data []byte, v interface{}) error {
+				return yaml.Unmarshal(data, v)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			options := &config.Options{
+				PackagePath:    filepath.Join(dir, "packages"),
+				Manifest:       filepath.Join(dir, tt.manifest),
+				AppVersion:     "2.0.0",
+				Sign:           true,
+				Key:            testKeyName,
+				KeyRing:        "testdata/testkeyring.gpg",
+				PassphraseFile: "testdata/passphrase-file.txt",
+			}
+			p := NewPackager(options, []string{"testdata/test-chart"}, nil)
+			require.NoError(t, p.CreatePackages(context.Background()))
+
+			data, err := os.ReadFile(options.Manifest)
+			require.NoError(t, err)
+			manifest := &Manifest{}
+			require.NoError(t, tt.unmarshal(data, manifest))
+			require.Len(t, manifest.Packages, 1)
+
+			packagePath := filepath.Join(options.PackagePath, "test-chart-0.1.0.tgz")
+			digest, err := provenance.DigestFile(packagePath)
+			require.NoError(t, err)
+			entry := manifest.Packages[0]
+			relPackagePath, err := filepath.Rel(filepath.Dir(options.Manifest), packagePath)
+			require.NoError(t, err)
+			assert.Equal(t, filepath.ToSlash(relPackagePath), entry.Path)
+			assert.Equal(t, "test-chart", entry.Name)
+			assert.Equal(t, "0.1.0", entry.Version)
+			assert.Equal(t, "2.0.0", entry.AppVersion)
+			assert.Equal(t, digest, entry.Digest)
+			assert.True(t, entry.Signed)
+			source, err := filepath.Abs("testdata/test-chart")
+			require.NoError(t, err)
+			assert.Equal(t, source, filepath.Join(filepath.Dir(options.Manifest), entry.Source))
+
+			packages, err := ReadManifest(options.Manifest)
+			require.NoError(t, err)
+			assert.Equal(t, []string{packagePath}, packages)
+		})
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	packagePath := filepath.Join(dir, "test-chart-0.1.0.tgz")
+	require.NoError(t, os.WriteFile(packagePath, []byte("package"), 0644))
+	digest, err := provenance.DigestFile(packagePath)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		manifest string
+		expected []string
+		error    string
+	}{
+		{
+			name:     "json",
+			manifest: `{"packages": [{"path": "test-chart-0.1.0.tgz", "name": "test-chart", "version": "0.1.0", "digest": "` + digest + `"}]}`,
+			expected: []string{packagePath},
+		},
+		{
+			name:     "yaml-without-digest",
+			manifest: "packages:\n- path: test-chart-0.1.0.tgz\n",
+			expected: []string{packagePath},
+		},
+		{
+			name:     "empty",
+			manifest: `{"packages": []}`,
+		},
+		{
+			name:     "digest-mismatch",
+			manifest: `{"packages": [{"path": "test-chart-0.1.0.tgz", "digest": "0000"}]}`,
+			error:    "does not match its digest in manifest",
+		},
+		{
+			name:     "missing-package",
+			manifest: `{"packages": [{"path": "other-0.1.0.tgz"}]}`,
+			error:    "no such file or directory",
+		},
+		{
+			name:     "unknown-field",
+			manifest: `{"charts": []}`,
+			error:    "error parsing package manifest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := filepath.Join(dir, "manifest.json")
+			require.NoError(t, os.WriteFile(manifestPath, []byte(tt.manifest), 0644))
+			packages, err := ReadManifest(manifestPath)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, packages)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// chartNode is a chart to package in the dependency graph.
+type chartNode struct {
+	path     string
+	metadata *chart.Metadata
+	// dependencies are the indices of the charts this chart depends on
+	// through a file:// repository in the sorted nodes.
+	dependencies []int
+	// repositories are the repositories the chart's other dependencies are
+	// downloaded from.
+	repositories []string
+}
+
+// sortByDependencies orders the chart paths so that charts come after the
+// charts they depend on through a file:// repository. The given order is kept
+// otherwise. Dependencies on charts which are not being packaged are ignored.
+func sortByDependencies(paths []string) ([]*chartNode, error) {
+	index := map[string]int{}
+	absPaths := make([]string, len(paths))
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		absPaths[i] = abs
+		index[abs] = i
+	}
+
+	dependencies := make([][]int, len(paths))
+	repositories := make([][]string, len(paths))
+	metadata := make([]*chart.Metadata, len(paths))
+	for i, path := range paths {
+		ch, err := loader.LoadDir(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading chart %s", path)
+		}
+		metadata[i] = ch.Metadata
+		for _, dep := range ch.Metadata.Dependencies {
+			if !strings.HasPrefix(dep.Repository, "file://") {
+				// Dependencies without a repository are vendored in charts/,
+				// and OCI registries are not chart repositories.
+				if dep.Repository != "" && !strings.HasPrefix(dep.Repository, "oci://") {
+					repositories[i] = append(repositories[i], dep.Repository)
+				}
+				continue
+			}
+			depPath := filepath.Join(absPaths[i], strings.TrimPrefix(dep.Repository, "file://"))
+			if j, ok := index[depPath]; ok && j != i {
+				dependencies[i] = append(dependencies[i], j)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(paths))
+	var stack []int
+	var sorted []*chartNode
+	position := make([]int, len(paths))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			var cycle []string
+			for k := len(stack) - 1; k >= 0; k-- {
+				cycle = append([]string{paths[stack[k]]}, cycle...)
+				if stack[k] == i {
+					break
+				}
+			}
+			return errors.Errorf("dependency cycle between charts: %s -> %s", strings.Join(cycle, " -> "), paths[i])
+		}
+
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, j := range dependencies[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+
+		node := &chartNode{path: paths[i], metadata: metadata[i], repositories: repositories[i]}
+		for _, j := range dependencies[i] {
+			node.dependencies = append(node.dependencies, position[j])
+		}
+		position[i] = len(sorted)
+		sorted = append(sorted, node)
+		return nil
+	}
+
+	for i := range paths {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,104 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    []string
+		expected []string
+		// dependencies are the indices of each chart's dependencies in the
+		// expected order.
+		dependencies [][]int
+		repositories [][]string
+		error        string
+	}{
+		{
+			name:         "dependencies-first",
+			paths:        []string{"testdata/dependencies/umbrella", "testdata/dependencies/sub", "testdata/dependencies/lib"},
+			expected:     []string{"testdata/dependencies/lib", "testdata/dependencies/sub", "testdata/dependencies/umbrella"},
+			dependencies: [][]int{nil, {0}, {1, 0}},
+		},
+		{
+			name:         "keeps-order-of-independent-charts",
+			paths:        []string{"testdata/test-chart", "testdata/dependencies/sub", "testdata/charts/apps/web", "testdata/dependencies/lib"},
+			expected:     []string{"testdata/test-chart", "testdata/dependencies/lib", "testdata/dependencies/sub", "testdata/charts/apps/web"},
+			dependencies: [][]int{nil, nil, {1}, nil},
+		},
+		{
+			name:         "ignores-dependencies-not-packaged",
+			paths:        []string{"testdata/dependencies/umbrella", "testdata/dependencies/lib"},
+			expected:     []string{"testdata/dependencies/lib", "testdata/dependencies/umbrella"},
+			dependencies: [][]int{nil, {0}},
+		},
+		{
+			name:         "remote-dependencies",
+			paths:        []string{"testdata/dependencies/umbrella", "testdata/dependencies/sub"},
+			expected:     []string{"testdata/dependencies/sub", "testdata/dependencies/umbrella"},
+			dependencies: [][]int{nil, {0}},
+			repositories: [][]string{nil, {"https://charts.bitnami.com/bitnami"}},
+		},
+		{
+			name:         "vendored-and-oci-dependencies",
+			paths:        []string{"testdata/dependencies/vendored"},
+			expected:     []string{"testdata/dependencies/vendored"},
+			dependencies: [][]int{nil},
+			repositories: [][]string{{"https://charts.bitnami.com/bitnami"}},
+		},
+		{
+			name:  "cycle",
+			paths: []string{"testdata/cycle/a", "testdata/cycle/b"},
+			error: "dependency cycle between charts: testdata/cycle/a -> testdata/cycle/b -> testdata/cycle/a",
+		},
+		{
+			name:  "invalid-chart-path",
+			paths: []string{"testdata/invalid-chart"},
+			error: "error loading chart testdata/invalid-chart",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, err := sortByDependencies(tt.paths)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+			var paths []string
+			var dependencies [][]int
+			for _, node := range sorted {
+				paths = append(paths, node.path)
+				dependencies = append(dependencies, node.dependencies)
+			}
+			assert.Equal(t, tt.expected, paths)
+			assert.Equal(t, tt.dependencies, dependencies)
+			if tt.repositories != nil {
+				var repositories [][]string
+				for _, node := range sorted {
+					repositories = append(repositories, node.repositories)
+				}
+				assert.Equal(t, tt.repositories, repositories)
+			}
+		})
+	}
+}
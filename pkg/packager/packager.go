@@ -15,94 +15,333 @@
 package packager
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+	"time"
 
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/downloader"
 	"helm.sh/helm/v3/pkg/getter"
 
-	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
 	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/git"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/registry"
 )
 
+// Git is the interface for the Git operations needed for packaging.
+type Git interface {
+	HeadMetadata(ctx context.Context, workingDir string) (*git.Metadata, error)
+}
+
 // Packager exposes the packager object
 type Packager struct {
 	config *config.Options
 	paths  []string
+	git    Git
+	// stdin is read for the keyring or passphrase if configured.
+	stdin io.Reader
 }
 
 // NewPackager returns a configured Packager
-func NewPackager(config *config.Options, paths []string) *Packager {
+func NewPackager(config *config.Options, paths []string, git Git) *Packager {
 	return &Packager{
 		config: config,
 		paths:  paths,
+		git:    git,
+		stdin:  os.Stdin,
 	}
 }
 
-// CreatePackages creates Helm chart packages
-func (p *Packager) CreatePackages() error {
+// CreatePackages creates Helm chart packages. Charts are packaged after the
+// charts they depend on through file:// repositories; independent charts are
+// packaged in parallel up to the configured concurrency. The output of each
+// chart is printed in packaging order once it is done. Cancelling ctx stops
+// packaging before the next chart is processed. The metadata policy is
+// enforced for all charts before any of them is packaged.
+func (p *Packager) CreatePackages(ctx context.Context) error {
 	helmClient := action.NewPackage()
-	helmClient.DependencyUpdate = true
+	helmClient.DependencyUpdate = !p.skipDependencyUpdate()
 	helmClient.Destination = p.config.PackagePath
+	var signer *signer
 	if p.config.Sign {
-		// expand the ~ to the full home dir
-		if strings.HasPrefix(p.config.KeyRing, "~") {
-			dir, err := homedir.Dir()
-			if err != nil {
-				panic(err)
-			}
-
-			p.config.KeyRing = strings.ReplaceAll(p.config.KeyRing, "~", dir)
+		stdin := p.stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		var err error
+		if signer, err = p.newSigner(stdin); err != nil {
+			return err
 		}
-
-		helmClient.Sign = true
-		helmClient.Key = p.config.Key
-		helmClient.Keyring = p.config.KeyRing
-		helmClient.PassphraseFile = p.config.PassphraseFile
 	}
 
 	settings := cli.New()
-	getters := getter.All(settings)
 	registryClient, err := registry.NewClient()
 	if err != nil {
 		return err
 	}
-
-	for i := 0; i < len(p.paths); i++ {
-		path, err := filepath.Abs(p.paths[i])
-		if err != nil {
-			return err
-		}
-		if _, err := os.Stat(p.paths[i]); err != nil {
-			return err
-		}
-
-		downloadManager := &downloader.Manager{
+	newDownloadManager := func(path string) *downloader.Manager {
+		return &downloader.Manager{
 			Out:              io.Discard,
 			ChartPath:        path,
 			Keyring:          helmClient.Keyring,
-			Getters:          getters,
+			Getters:          getter.All(settings),
 			Debug:            settings.Debug,
 			RepositoryConfig: settings.RepositoryConfig,
 			RepositoryCache:  settings.RepositoryCache,
 			RegistryClient:   registryClient,
+			// The repository cache is shared by all charts and filled
+			// once below, instead of by every chart concurrently.
+			SkipUpdate: true,
+		}
+	}
+
+	// Charts depending on other charts through file:// repositories are
+	// packaged after them, so that they pick up the current content.
+	nodes, err := sortByDependencies(p.paths)
+	if err != nil {
+		return err
+	}
+
+	var gitMetadata *git.Metadata
+	if p.usesGitMetadata() {
+		if gitMetadata, err = p.git.HeadMetadata(ctx, ""); err != nil {
+			return errors.Wrap(err, "error reading Git metadata for version templates")
+		}
+	}
+	var sourceDate time.Time
+	if p.config.Reproducible {
+		if sourceDate, err = p.sourceDate(ctx, gitMetadata); err != nil {
+			return err
+		}
+	}
+
+	// The policy is checked for the metadata the packages will have, before
+	// anything is downloaded or packaged.
+	clients := make([]*action.Package, len(nodes))
+	packagedMetadata := make([]*chart.Metadata, len(nodes))
+	for i, node := range nodes {
+		if clients[i], err = p.packageClient(helmClient, node.path, gitMetadata); err != nil {
+			return err
+		}
+		metadata := *node.metadata
+		if clients[i].Version != "" {
+			metadata.Version = clients[i].Version
 		}
-		if err := downloadManager.Build(); err != nil {
+		if clients[i].AppVersion != "" {
+			metadata.AppVersion = clients[i].AppVersion
+		}
+		packagedMetadata[i] = &metadata
+	}
+	if err := EnforcePolicy(os.Stdout, &p.config.Policy, packagedMetadata); err != nil {
+		return err
+	}
+
+	var repositories []string
+	for _, node := range nodes {
+		repositories = append(repositories, node.repositories...)
+	}
+	if len(repositories) > 0 && !p.skipDependencyUpdate() {
+		// Register the dependency repositories in a temporary repository
+		// config, so that they need not be added with 'helm repo add'.
+		dir, err := os.MkdirTemp("", "chart-releaser-repositories-")
+		if err != nil {
 			return err
 		}
-		packageRun, err := helmClient.Run(path, nil)
+		defer os.RemoveAll(dir)
+
+		repositoryConfig := filepath.Join(dir, "repositories.yaml")
+		entries, err := p.writeRepositoryConfig(settings.RepositoryConfig, repositoryConfig, repositories)
 		if err != nil {
-			fmt.Printf("Failed to package chart in %s (%s)\n", path, err.Error())
 			return err
 		}
+		settings.RepositoryConfig = repositoryConfig
+		settings.RepositoryCache = filepath.Join(dir, "cache")
+
+		if err := downloadIndexFiles(entries, settings.RepositoryCache, getter.All(settings)); err != nil {
+			return err
+		}
+	}
+
+	concurrency := p.config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var failure error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failure == nil {
+			failure = err
+			cancel()
+		}
+	}
+
+	results := make([]*packageResult, len(nodes))
+	for i := range nodes {
+		results[i] = &packageResult{done: make(chan struct{})}
+	}
+	slots := make(chan struct{}, concurrency)
+	for i, node := range nodes {
+		go func(node *chartNode, client *action.Package, result *packageResult) {
+			defer close(result.done)
+			for _, j := range node.dependencies {
+				<-results[j].done
+				if results[j].err != nil {
+					result.err = errors.Errorf("dependency %s of chart %s was not packaged", nodes[j].path, node.path)
+					return
+				}
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				result.err = ctx.Err()
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				result.err = err
+				return
+			}
+
+			packagePath, err := p.createPackage(&result.output, client, newDownloadManager, signer, sourceDate, node.path)
+			result.packagePath = packagePath
+			if result.err = err; result.err != nil {
+				fail(result.err)
+			}
+		}(node, clients[i], results[i])
+	}
+
+	for _, result := range results {
+		<-result.done
+		fmt.Print(result.output.String())
+	}
+
+	if failure != nil {
+		return failure
+	}
+	for _, result := range results {
+		if result.err != nil {
+			return result.err
+		}
+	}
 
-		fmt.Printf("Successfully packaged chart in %s and saved it to: %s\n", path, packageRun)
+	if p.config.Manifest != "" {
+		manifest := &Manifest{Packages: []*ManifestEntry{}}
+		for i, result := range results {
+			entry, err := newManifestEntry(p.config.Manifest, result.packagePath, nodes[i].path)
+			if err != nil {
+				return err
+			}
+			manifest.Packages = append(manifest.Packages, entry)
+		}
+		if err := WriteManifest(p.config.Manifest, manifest); err != nil {
+			return errors.Wrap(err, "error writing package manifest")
+		}
+		fmt.Printf("Wrote package manifest to %s\n", p.config.Manifest)
 	}
 	return nil
 }
+
+// skipDependencyUpdate reports whether the vendored dependencies are used as
+// they are, without network access.
+func (p *Packager) skipDependencyUpdate() bool {
+	return p.config.SkipDependencyUpdate || p.config.Offline
+}
+
+// packageResult collects the output of packaging a chart until it can be
+// printed.
+type packageResult struct {
+	output      bytes.Buffer
+	packagePath string
+	err         error
+	done        chan struct{}
+}
+
+// createPackage updates the dependencies of the chart in the given path, or
+// verifies the vendored ones if dependency updates are skipped, lints, render
+// tests and checks its Kubernetes APIs if configured, packages it, scans the
+// package for likely secrets and junk files and signs it, writing progress to
+// out. The SBOM is written if configured.
+// Reproducible packages are normalized with sourceDate as the time of all
+// files.
+func (p *Packager) createPackage(out io.Writer, helmClient *action.Package, newDownloadManager func(string) *downloader.Manager, signer *signer, sourceDate time.Time, chartPath string) (string, error) {
+	path, err := filepath.Abs(chartPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(chartPath); err != nil {
+		return "", err
+	}
+
+	if p.skipDependencyUpdate() {
+		if err := verifyDependencies(path); err != nil {
+			return "", err
+		}
+	} else if err := newDownloadManager(path).Build(); err != nil {
+		return "", err
+	}
+	// Linting needs the dependencies, so it runs after they are updated.
+	if p.config.Lint || p.config.LintStrict {
+		if err := lintChart(out, path, p.config.LintStrict); err != nil {
+			return "", err
+		}
+	}
+	if p.config.RenderTest {
+		if err := renderTestChart(out, path); err != nil {
+			return "", err
+		}
+	}
+	if p.config.CheckAPIs {
+		if err := checkAPIs(out, p.config, path); err != nil {
+			return "", err
+		}
+	}
+	packageRun, err := helmClient.Run(path, nil)
+	var images []string
+	if err == nil && p.config.SBOM != "" {
+		if images, err = chartImages(path, packageRun, p.config.SBOMValues); err == nil {
+			err = annotateImages(packageRun, images)
+		}
+	}
+	if err == nil && p.config.Reproducible {
+		err = normalizeArchive(packageRun, sourceDate)
+	}
+	if err == nil {
+		if err = scanPackage(out, &p.config.Scan, packageRun); err != nil {
+			// The package must not be uploaded by a later step.
+			os.Remove(packageRun)
+		}
+	}
+	if err == nil && p.config.SBOM != "" {
+		created := sourceDate
+		if !p.config.Reproducible {
+			created = time.Now()
+		}
+		// The SBOM has the digest of the final package.
+		err = writeSBOM(p.config.SBOM, packageRun, images, created)
+	}
+	if err == nil && signer != nil {
+		err = signer.sign(packageRun)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Failed to package chart in %s (%s)\n", path, err.Error())
+		return "", err
+	}
+
+	fmt.Fprintf(out, "Successfully packaged chart in %s and saved it to: %s\n", path, packageRun)
+	return packageRun, nil
+}
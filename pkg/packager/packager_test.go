@@ -15,12 +15,15 @@
 package packager
 
 import (
+	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
 
 	"github.com/tklauenberg/chart-releaser/pkg/config"
 )
@@ -58,6 +61,18 @@ func TestPackager_CreatePackages(t *testing.T) {
 			options:   &config.Options{PackagePath: packagePath},
 			error:     true,
 		},
+		{
+			name:      "lint-valid-chart",
+			chartPath: "testdata/test-chart",
+			options:   &config.Options{PackagePath: packagePath, LintStrict: true},
+			error:     false,
+		},
+		{
+			name:      "lint-invalid-chart",
+			chartPath: "testdata/lint/broken",
+			options:   &config.Options{PackagePath: packagePath, Lint: true},
+			error:     true,
+		},
 		{
 			name:      "valid-chart-path-with-provenance",
 			chartPath: "testdata/test-chart",
@@ -82,7 +97,7 @@ func TestPackager_CreatePackages(t *testing.T) {
 				paths:  []string{tt.chartPath},
 				config: tt.options,
 			}
-			err := p.CreatePackages()
+			err := p.CreatePackages(context.Background())
 			if tt.error {
 				require.Error(t, err)
 			} else {
@@ -95,3 +110,64 @@ func TestPackager_CreatePackages(t *testing.T) {
 		})
 	}
 }
+
+func TestPackager_CreatePackagesConcurrently(t *testing.T) {
+	chartsDir := t.TempDir()
+	copyDir(t, "testdata/dependencies/lib", filepath.Join(chartsDir, "lib"))
+	copyDir(t, "testdata/dependencies/sub", filepath.Join(chartsDir, "sub"))
+	copyDir(t, "testdata/charts/apps/web", filepath.Join(chartsDir, "web"))
+	copyDir(t, "testdata/test-chart", filepath.Join(chartsDir, "test-chart"))
+	packagePath := t.TempDir()
+
+	p := NewPackager(&config.Options{PackagePath: packagePath, Concurrency: 4}, []string{
+		filepath.Join(chartsDir, "sub"),
+		filepath.Join(chartsDir, "web"),
+		filepath.Join(chartsDir, "lib"),
+		filepath.Join(chartsDir, "test-chart"),
+	}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+
+	for _, name := range []string{"lib-0.1.0.tgz", "sub-0.1.0.tgz", "web-1.0.0.tgz", "test-chart-0.1.0.tgz"} {
+		assert.FileExists(t, filepath.Join(packagePath, name))
+	}
+	sub, err := loader.Load(filepath.Join(packagePath, "sub-0.1.0.tgz"))
+	require.NoError(t, err)
+	require.Len(t, sub.Dependencies(), 1)
+	assert.Equal(t, "lib", sub.Dependencies()[0].Name())
+}
+
+func TestPackager_CreatePackagesConcurrentlyFailure(t *testing.T) {
+	packagePath := t.TempDir()
+	chartsDir := t.TempDir()
+	copyDir(t, "testdata/test-chart", filepath.Join(chartsDir, "test-chart"))
+	copyDir(t, "testdata/charts/apps/web", filepath.Join(chartsDir, "web"))
+	// A directory in place of the package makes packaging the chart fail.
+	require.NoError(t, os.Mkdir(filepath.Join(packagePath, "web-1.0.0.tgz"), 0755))
+
+	p := NewPackager(&config.Options{PackagePath: packagePath, Concurrency: 2}, []string{
+		filepath.Join(chartsDir, "test-chart"),
+		filepath.Join(chartsDir, "web"),
+	}, nil)
+	require.Error(t, p.CreatePackages(context.Background()))
+}
+
+func copyDir(t *testing.T, src string, dst string) {
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	require.NoError(t, err)
+}
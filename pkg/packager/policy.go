@@ -0,0 +1,147 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// defaultLicenseAnnotation is the Chart.yaml annotation checked by the license
+// rule unless configured otherwise, see
+// https://artifacthub.io/docs/topics/annotations/helm/.
+const defaultLicenseAnnotation = "artifacthub.io/license"
+
+// policyRule checks a chart's metadata and returns a message if it violates
+// the rule.
+type policyRule struct {
+	name  string
+	check func(policy *config.Policy, metadata *chart.Metadata) string
+}
+
+// policyRules are checked in this order.
+var policyRules = []policyRule{
+	{"maintainers", func(_ *config.Policy, metadata *chart.Metadata) string {
+		if len(metadata.Maintainers) == 0 {
+			return "no maintainers"
+		}
+		return ""
+	}},
+	{"home", func(_ *config.Policy, metadata *chart.Metadata) string {
+		if strings.TrimSpace(metadata.Home) == "" {
+			return "no home URL"
+		}
+		return ""
+	}},
+	{"sources", func(_ *config.Policy, metadata *chart.Metadata) string {
+		if len(metadata.Sources) == 0 {
+			return "no source URLs"
+		}
+		return ""
+	}},
+	{"icon", func(_ *config.Policy, metadata *chart.Metadata) string {
+		if strings.TrimSpace(metadata.Icon) == "" {
+			return "no icon URL"
+		}
+		return ""
+	}},
+	{"license", func(policy *config.Policy, metadata *chart.Metadata) string {
+		annotation := policy.LicenseAnnotation
+		if annotation == "" {
+			annotation = defaultLicenseAnnotation
+		}
+		if strings.TrimSpace(metadata.Annotations[annotation]) == "" {
+			return fmt.Sprintf("no license annotation %q", annotation)
+		}
+		return ""
+	}},
+	{"app-version", func(_ *config.Policy, metadata *chart.Metadata) string {
+		if metadata.AppVersion == "" {
+			return "no appVersion"
+		}
+		// A leading 'v' is common for application versions and allowed.
+		if _, err := semver.StrictNewVersion(strings.TrimPrefix(metadata.AppVersion, "v")); err != nil {
+			return fmt.Sprintf("appVersion %q is not a valid semantic version", metadata.AppVersion)
+		}
+		return ""
+	}},
+}
+
+// PolicyViolation is a chart metadata policy rule the chart violates.
+type PolicyViolation struct {
+	Rule    string
+	Level   string
+	Message string
+}
+
+func (v *PolicyViolation) String() string {
+	level := "[WARNING]"
+	if v.Level == LevelError {
+		level = "[ERROR]"
+	}
+	return fmt.Sprintf("%s %s: %s", level, v.Rule, v.Message)
+}
+
+// CheckPolicy returns the rules of the policy the chart metadata violates.
+func CheckPolicy(policy *config.Policy, metadata *chart.Metadata) []*PolicyViolation {
+	levels := policy.Rules()
+	var violations []*PolicyViolation
+	for _, rule := range policyRules {
+		level := checkLevel(levels[rule.name], LevelOff)
+		if level == LevelOff {
+			continue
+		}
+		if message := rule.check(policy, metadata); message != "" {
+			violations = append(violations, &PolicyViolation{Rule: rule.name, Level: level, Message: message})
+		}
+	}
+	return violations
+}
+
+// EnforcePolicy checks the metadata of all charts against the policy and
+// writes the violations to out, grouped by chart. An error is returned if any
+// chart violates a rule configured as an error.
+func EnforcePolicy(out io.Writer, policy *config.Policy, charts []*chart.Metadata) error {
+	var failed []string
+	for _, metadata := range charts {
+		violations := CheckPolicy(policy, metadata)
+		if len(violations) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "==> Chart %s %s violates the metadata policy\n", metadata.Name, metadata.Version)
+		isFailed := false
+		for _, violation := range violations {
+			fmt.Fprintln(out, violation)
+			if violation.Level == LevelError {
+				isFailed = true
+			}
+		}
+		if isFailed {
+			failed = append(failed, fmt.Sprintf("%s-%s", metadata.Name, metadata.Version))
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("charts violate the metadata policy: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
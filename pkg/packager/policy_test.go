@@ -0,0 +1,180 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// strictPolicy configures all rules as errors.
+var strictPolicy = config.Policy{
+	Maintainers: "error",
+	Home:        "error",
+	Sources:     "error",
+	Icon:        "error",
+	License:     "error",
+	AppVersion:  "error",
+}
+
+func compliantMetadata() *chart.Metadata {
+	return &chart.Metadata{
+		Name:        "compliant",
+		Version:     "1.0.0",
+		AppVersion:  "v2.3.4",
+		Home:        "https://example.com",
+		Sources:     []string{"https://github.com/example/compliant"},
+		Icon:        "https://example.com/icon.png",
+		Maintainers: []*chart.Maintainer{{Name: "Jane Doe"}},
+		Annotations: map[string]string{"artifacthub.io/license": "Apache-2.0"},
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   config.Policy
+		metadata func(metadata *chart.Metadata)
+		expected []string
+	}{
+		{
+			name:     "compliant",
+			policy:   strictPolicy,
+			metadata: func(metadata *chart.Metadata) {},
+		},
+		{
+			name:   "all-violated",
+			policy: strictPolicy,
+			metadata: func(metadata *chart.Metadata) {
+				*metadata = chart.Metadata{Name: "empty", Version: "1.0.0"}
+			},
+			expected: []string{
+				"[ERROR] maintainers: no maintainers",
+				"[ERROR] home: no home URL",
+				"[ERROR] sources: no source URLs",
+				"[ERROR] icon: no icon URL",
+				`[ERROR] license: no license annotation "artifacthub.io/license"`,
+				"[ERROR] app-version: no appVersion",
+			},
+		},
+		{
+			name:   "rules-off-by-default",
+			policy: config.Policy{Icon: "warning"},
+			metadata: func(metadata *chart.Metadata) {
+				*metadata = chart.Metadata{Name: "empty", Version: "1.0.0"}
+			},
+			expected: []string{"[WARNING] icon: no icon URL"},
+		},
+		{
+			name:   "invalid-app-version",
+			policy: config.Policy{AppVersion: "error", Maintainers: "off"},
+			metadata: func(metadata *chart.Metadata) {
+				metadata.AppVersion = "1.16"
+				metadata.Maintainers = nil
+			},
+			expected: []string{`[ERROR] app-version: appVersion "1.16" is not a valid semantic version`},
+		},
+		{
+			name:     "custom-license-annotation",
+			policy:   config.Policy{License: "warning", LicenseAnnotation: "example.com/license"},
+			metadata: func(metadata *chart.Metadata) {},
+			expected: []string{`[WARNING] license: no license annotation "example.com/license"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := compliantMetadata()
+			tt.metadata(metadata)
+			var actual []string
+			for _, violation := range CheckPolicy(&tt.policy, metadata) {
+				actual = append(actual, violation.String())
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestEnforcePolicy(t *testing.T) {
+	policy := config.Policy{Icon: "warning", Home: "error"}
+	noIcon := compliantMetadata()
+	noIcon.Name, noIcon.Icon = "no-icon", ""
+	noHome := compliantMetadata()
+	noHome.Name, noHome.Home, noHome.Icon = "no-home", "", ""
+
+	var out bytes.Buffer
+	err := EnforcePolicy(&out, &policy, []*chart.Metadata{compliantMetadata(), noIcon, noHome})
+	require.Error(t, err)
+	assert.Equal(t, "charts violate the metadata policy: no-home-1.0.0", err.Error())
+	assert.Equal(t, "==> Chart no-icon 1.0.0 violates the metadata policy\n"+
+		"[WARNING] icon: no icon URL\n"+
+		"==> Chart no-home 1.0.0 violates the metadata policy\n"+
+		"[ERROR] home: no home URL\n"+
+		"[WARNING] icon: no icon URL\n",
+		out.String())
+
+	out.Reset()
+	require.NoError(t, EnforcePolicy(&out, &policy, []*chart.Metadata{compliantMetadata(), noIcon}))
+}
+
+func TestPackager_CreatePackagesPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *config.Options
+		error   string
+	}{
+		{
+			name:    "no-policy",
+			options: &config.Options{},
+		},
+		{
+			name:    "violation-as-warning",
+			options: &config.Options{Policy: config.Policy{Icon: "warning"}},
+		},
+		{
+			name:    "violation-as-error",
+			options: &config.Options{Policy: config.Policy{Maintainers: "error"}},
+			error:   "charts violate the metadata policy: test-chart-0.1.0",
+		},
+		{
+			name:    "overridden-app-version",
+			options: &config.Options{Policy: config.Policy{AppVersion: "error"}, AppVersion: "1.16"},
+			error:   "charts violate the metadata policy: test-chart-0.1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.PackagePath = t.TempDir()
+			p := NewPackager(tt.options, []string{"testdata/test-chart"}, nil)
+			err := p.CreatePackages(context.Background())
+			packageFile := filepath.Join(tt.options.PackagePath, "test-chart-0.1.0.tgz")
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.error, err.Error())
+				assert.NoFileExists(t, packageFile)
+				return
+			}
+			require.NoError(t, err)
+			assert.FileExists(t, packageFile)
+		})
+	}
+}
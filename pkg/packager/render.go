@@ -0,0 +1,97 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// renderTestChart renders the chart in the given path offline, once with the
+// chart's default values and once for every ci/*-values.yaml file, the way
+// 'helm install --dry-run --client-only' does. All failures are written to
+// out. An error is returned if any render failed or produced invalid YAML.
+func renderTestChart(out io.Writer, chartPath string) error {
+	valuesFiles, err := filepath.Glob(filepath.Join(chartPath, "ci", "*-values.yaml"))
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	fmt.Fprintf(out, "==> Rendering %s\n", chartPath)
+	for _, valuesFile := range append([]string{""}, valuesFiles...) {
+		vals := map[string]interface{}{}
+		if valuesFile != "" {
+			values, err := chartutil.ReadValuesFile(valuesFile)
+			if err != nil {
+				return errors.Wrapf(err, "error reading values file %s", valuesFile)
+			}
+			vals = values.AsMap()
+			fmt.Fprintf(out, "==> Rendering %s with values %s\n", chartPath, filepath.Base(valuesFile))
+		}
+
+		manifests, err := renderChart(chartPath, vals)
+		if err == nil {
+			// Notes are not manifests, helm install removes them as well.
+			for name := range manifests {
+				if strings.HasSuffix(name, "NOTES.txt") {
+					delete(manifests, name)
+				}
+			}
+			_, _, err = releaseutil.SortManifests(manifests, chartutil.DefaultCapabilities.APIVersions, releaseutil.InstallOrder)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "[ERROR] %s\n", err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return errors.Errorf("chart %s failed the render test", chartPath)
+	}
+	return nil
+}
+
+// renderChart renders the chart or chart package in the given path with the
+// given values, like 'helm template' does.
+func renderChart(path string, vals map[string]interface{}) (map[string]string, error) {
+	// Loaded every time, as disabled dependencies are removed from it.
+	ch, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := chartutil.ProcessDependencies(ch, vals); err != nil {
+		return nil, err
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      "release-name",
+		Namespace: "default",
+		Revision:  1,
+		IsInstall: true,
+	}
+	values, err := chartutil.ToRenderValues(ch, vals, options, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Render(ch, values)
+}
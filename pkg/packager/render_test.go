@@ -0,0 +1,112 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+func TestRenderTestChart(t *testing.T) {
+	tests := []struct {
+		name   string
+		chart  string
+		output []string
+		error  string
+	}{
+		{
+			name:  "valid",
+			chart: "testdata/render/valid",
+			output: []string{
+				"==> Rendering testdata/render/valid\n",
+				"==> Rendering testdata/render/valid with values scaled-values.yaml\n",
+			},
+		},
+		{
+			name:  "fails-with-ci-values",
+			chart: "testdata/render/values-error",
+			output: []string{
+				"==> Rendering testdata/render/values-error with values database-values.yaml\n[ERROR] ",
+				"database.password is required",
+			},
+			error: "chart testdata/render/values-error failed the render test",
+		},
+		{
+			name:   "invalid-yaml",
+			chart:  "testdata/render/invalid-yaml",
+			output: []string{"[ERROR] YAML parse error on invalid-yaml/templates/configmap.yaml"},
+			error:  "chart testdata/render/invalid-yaml failed the render test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := renderTestChart(&out, tt.chart)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.error, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.NotContains(t, out.String(), "[ERROR]")
+			}
+			for _, expected := range tt.output {
+				assert.Contains(t, out.String(), expected)
+			}
+		})
+	}
+}
+
+func TestPackager_CreatePackagesRenderTest(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart string
+		error bool
+	}{
+		{
+			name:  "valid",
+			chart: "valid",
+		},
+		{
+			name:  "not-packaged-on-failure",
+			chart: "values-error",
+			error: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := t.TempDir()
+			p := NewPackager(&config.Options{
+				PackagePath: packagePath,
+				RenderTest:  true,
+			}, []string{filepath.Join("testdata/render", tt.chart)}, nil)
+			err := p.CreatePackages(context.Background())
+			packageFile := filepath.Join(packagePath, tt.chart+"-0.1.0.tgz")
+			if tt.error {
+				require.Error(t, err)
+				assert.NoFileExists(t, packageFile)
+				return
+			}
+			require.NoError(t, err)
+			assert.FileExists(t, packageFile)
+		})
+	}
+}
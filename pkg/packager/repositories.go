@@ -0,0 +1,134 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// writeRepositoryConfig writes a Helm repository config to path. It contains
+// the repositories from the existing config at base, the repositories from
+// the cr config with their credentials, and every other repository in urls.
+// The entries of the repositories in urls, which may also be aliases, are
+// returned.
+func (p *Packager) writeRepositoryConfig(base string, path string, urls []string) ([]*repo.Entry, error) {
+	file, err := repo.LoadFile(base)
+	if err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			return nil, err
+		}
+		file = repo.NewFile()
+	}
+
+	for _, r := range p.config.Repositories {
+		if r.URL == "" {
+			return nil, errors.New("repository in config without url")
+		}
+		entry := &repo.Entry{
+			Name:                  r.Name,
+			URL:                   r.URL,
+			Username:              os.ExpandEnv(r.Username),
+			Password:              os.ExpandEnv(r.Password),
+			CAFile:                r.CAFile,
+			CertFile:              r.CertFile,
+			KeyFile:               r.KeyFile,
+			InsecureSkipTLSverify: r.InsecureSkipTLSVerify,
+		}
+		if existing := findRepository(file, r.URL); existing != nil {
+			if entry.Name == "" {
+				entry.Name = existing.Name
+			} else if entry.Name != existing.Name {
+				file.Remove(existing.Name)
+			}
+		}
+		if entry.Name == "" {
+			entry.Name = repositoryName(r.URL)
+		}
+		file.Update(entry)
+	}
+
+	var used []*repo.Entry
+	for _, url := range urls {
+		var entry *repo.Entry
+		switch {
+		case strings.HasPrefix(url, "oci://"):
+			// OCI registries are not repositories.
+			continue
+		case strings.HasPrefix(url, "@") || strings.HasPrefix(url, "alias:"):
+			name := strings.TrimPrefix(strings.TrimPrefix(url, "@"), "alias:")
+			if entry = file.Get(name); entry == nil {
+				return nil, errors.Errorf("no repository named %q configured", name)
+			}
+		default:
+			if entry = findRepository(file, url); entry == nil {
+				entry = &repo.Entry{Name: repositoryName(url), URL: url}
+				file.Add(entry)
+			}
+		}
+		if !containsEntry(used, entry) {
+			used = append(used, entry)
+		}
+	}
+
+	return used, file.WriteFile(path, 0600)
+}
+
+// downloadIndexFiles downloads the index files of the repositories into the
+// cache directory.
+func downloadIndexFiles(entries []*repo.Entry, cache string, getters getter.Providers) error {
+	for _, entry := range entries {
+		r, err := repo.NewChartRepository(entry, getters)
+		if err != nil {
+			return err
+		}
+		r.CachePath = cache
+		if _, err := r.DownloadIndexFile(); err != nil {
+			return errors.Wrapf(err, "error downloading index of chart repository %s", entry.URL)
+		}
+		fmt.Printf("Downloaded index of chart repository %s\n", entry.URL)
+	}
+	return nil
+}
+
+func containsEntry(entries []*repo.Entry, entry *repo.Entry) bool {
+	for _, e := range entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// findRepository returns the entry for the repository with the given URL.
+func findRepository(file *repo.File, url string) *repo.Entry {
+	for _, entry := range file.Repositories {
+		if strings.TrimSuffix(entry.URL, "/") == strings.TrimSuffix(url, "/") {
+			return entry
+		}
+	}
+	return nil
+}
+
+// repositoryName derives a stable name for a repository from its URL.
+func repositoryName(url string) string {
+	return fmt.Sprintf("cr-%x", sha256.Sum256([]byte(strings.TrimSuffix(url, "/"))))[:15]
+}
@@ -0,0 +1,169 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+func TestPackager_writeRepositoryConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	baseFile := repo.NewFile()
+	baseFile.Add(
+		&repo.Entry{Name: "stable", URL: "https://charts.example.com/stable"},
+		&repo.Entry{Name: "private", URL: "https://private.example.com/charts/"},
+	)
+	require.NoError(t, baseFile.WriteFile(base, 0600))
+	t.Setenv("TEST_CHARTS_PASSWORD", "secret")
+
+	p := NewPackager(&config.Options{Repositories: []config.Repository{
+		{URL: "https://private.example.com/charts", Username: "bot", Password: "${TEST_CHARTS_PASSWORD}"},
+		{Name: "internal", URL: "https://internal.example.com", CAFile: "/etc/ssl/internal.pem"},
+	}}, nil, nil)
+
+	path := filepath.Join(dir, "repositories.yaml")
+	entries, err := p.writeRepositoryConfig(base, path, []string{
+		"https://charts.example.com/stable/",
+		"https://charts.bitnami.com/bitnami",
+		"https://charts.bitnami.com/bitnami",
+		"@internal",
+		"oci://registry.example.com/charts",
+	})
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(t, []string{"stable", repositoryName("https://charts.bitnami.com/bitnami"), "internal"}, names)
+
+	file, err := repo.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Entry{
+		{Name: "stable", URL: "https://charts.example.com/stable"},
+		{Name: "private", URL: "https://private.example.com/charts", Username: "bot", Password: "secret"},
+		{Name: "internal", URL: "https://internal.example.com", CAFile: "/etc/ssl/internal.pem"},
+		{Name: repositoryName("https://charts.bitnami.com/bitnami"), URL: "https://charts.bitnami.com/bitnami"},
+	}, file.Repositories)
+
+	// The base config is left untouched.
+	baseFile, err = repo.LoadFile(base)
+	require.NoError(t, err)
+	assert.Len(t, baseFile.Repositories, 2)
+}
+
+func TestPackager_writeRepositoryConfigWithoutBase(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPackager(&config.Options{}, nil, nil)
+	path := filepath.Join(dir, "repositories.yaml")
+	_, err := p.writeRepositoryConfig(filepath.Join(dir, "missing.yaml"), path, []string{"https://charts.example.com"})
+	require.NoError(t, err)
+
+	file, err := repo.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []*repo.Entry{{Name: repositoryName("https://charts.example.com"), URL: "https://charts.example.com"}}, file.Repositories)
+}
+
+func TestPackager_writeRepositoryConfigUnknownAlias(t *testing.T) {
+	dir := t.TempDir()
+	p := NewPackager(&config.Options{}, nil, nil)
+	_, err := p.writeRepositoryConfig(filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "repositories.yaml"), []string{"@stable"})
+	require.EqualError(t, err, `no repository named "stable" configured`)
+}
+
+func TestPackager_CreatePackagesWithPrivateRepository(t *testing.T) {
+	// Serve a chart repository with the lib chart, which requires basic auth.
+	repoDir := t.TempDir()
+	lib, err := loader.LoadDir("testdata/dependencies/lib")
+	require.NoError(t, err)
+	_, err = chartutil.Save(lib, repoDir)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "bot" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.FileServer(http.Dir(repoDir)).ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	index, err := repo.IndexDirectory(repoDir, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644))
+
+	// No repositories are configured in Helm.
+	helmHome := t.TempDir()
+	t.Setenv("HELM_REPOSITORY_CONFIG", filepath.Join(helmHome, "repositories.yaml"))
+	t.Setenv("HELM_REPOSITORY_CACHE", filepath.Join(helmHome, "cache"))
+
+	chartPath := filepath.Join(t.TempDir(), "app")
+	require.NoError(t, os.MkdirAll(chartPath, 0755))
+	chartYaml := fmt.Sprintf("apiVersion: v2\nname: app\nversion: 1.0.0\ndependencies:\n  - name: lib\n    version: 0.1.0\n    repository: %s\n", server.URL)
+	require.NoError(t, os.WriteFile(filepath.Join(chartPath, "Chart.yaml"), []byte(chartYaml), 0644))
+
+	packagePath := t.TempDir()
+	p := NewPackager(&config.Options{
+		PackagePath:  packagePath,
+		Repositories: []config.Repository{{URL: server.URL, Username: "bot", Password: "secret"}},
+	}, []string{chartPath}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+
+	ch, err := loader.Load(filepath.Join(packagePath, "app-1.0.0.tgz"))
+	require.NoError(t, err)
+	require.Len(t, ch.Dependencies(), 1)
+	assert.Equal(t, "lib", ch.Dependencies()[0].Name())
+	assert.NoFileExists(t, filepath.Join(helmHome, "repositories.yaml"))
+
+	// Without credentials, the repository cannot be read.
+	p = NewPackager(&config.Options{PackagePath: packagePath}, []string{chartPath}, nil)
+	require.NoError(t, os.RemoveAll(filepath.Join(chartPath, "charts")))
+	require.Error(t, p.CreatePackages(context.Background()))
+}
+
+func TestPackager_CreatePackagesWithVendoredDependency(t *testing.T) {
+	helmHome := t.TempDir()
+	t.Setenv("HELM_REPOSITORY_CONFIG", filepath.Join(helmHome, "repositories.yaml"))
+	t.Setenv("HELM_REPOSITORY_CACHE", filepath.Join(helmHome, "cache"))
+
+	// The dependency has no repository, it is vendored in charts/.
+	chartPath := filepath.Join(t.TempDir(), "app")
+	subPath := filepath.Join(chartPath, "charts", "sub")
+	require.NoError(t, os.MkdirAll(subPath, 0755))
+	chartYaml := "apiVersion: v2\nname: app\nversion: 1.0.0\ndependencies:\n  - name: sub\n    version: 0.1.0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(chartPath, "Chart.yaml"), []byte(chartYaml), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(subPath, "Chart.yaml"), []byte("apiVersion: v2\nname: sub\nversion: 0.1.0\n"), 0644))
+
+	packagePath := t.TempDir()
+	p := NewPackager(&config.Options{PackagePath: packagePath}, []string{chartPath}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+
+	ch, err := loader.Load(filepath.Join(packagePath, "app-1.0.0.tgz"))
+	require.NoError(t, err)
+	require.Len(t, ch.Dependencies(), 1)
+	assert.Equal(t, "sub", ch.Dependencies()[0].Name())
+}
@@ -0,0 +1,152 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tklauenberg/chart-releaser/pkg/git"
+)
+
+// sourceDateEpochEnv is the environment variable which sets the timestamp of
+// reproducible builds, see https://reproducible-builds.org/specs/source-date-epoch/.
+const sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// gzipOSUnknown is the operating system recorded in the gzip header, which
+// Go's writer uses by default.
+const gzipOSUnknown = 255
+
+// sourceDate returns the timestamp of the files in reproducible packages. It
+// is taken from SOURCE_DATE_EPOCH, or else is the time of the last commit.
+func (p *Packager) sourceDate(ctx context.Context, gitMetadata *git.Metadata) (time.Time, error) {
+	if value, ok := os.LookupEnv(sourceDateEpochEnv); ok {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, errors.Errorf("invalid %s %q, must be a Unix timestamp", sourceDateEpochEnv, value)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	if gitMetadata == nil {
+		if p.git == nil {
+			return time.Time{}, errors.Errorf("reproducible packages need %s or a Git repository", sourceDateEpochEnv)
+		}
+		var err error
+		if gitMetadata, err = p.git.HeadMetadata(ctx, ""); err != nil {
+			return time.Time{}, errors.Wrapf(err, "error reading the last commit time, set %s instead", sourceDateEpochEnv)
+		}
+	}
+	return gitMetadata.CommitTime, nil
+}
+
+// normalizeArchive rewrites the chart package in the given path so that it
+// only depends on the content of the chart: the files are sorted by name, have
+// the given modification time and no owner, and the gzip header has no
+// timestamp.
+func normalizeArchive(path string, modTime time.Time) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	zipReader, err := gzip.NewReader(source)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", path)
+	}
+	type entry struct {
+		header *tar.Header
+		data   []byte
+	}
+	var entries []entry
+	tarReader := tar.NewReader(zipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s", path)
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s", path)
+		}
+		entries = append(entries, entry{
+			header: &tar.Header{
+				Typeflag: header.Typeflag,
+				Name:     header.Name,
+				Linkname: header.Linkname,
+				Mode:     header.Mode,
+				Size:     header.Size,
+				ModTime:  modTime,
+			},
+			data: data,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].header.Name < entries[j].header.Name
+	})
+
+	// Write next to the package, so that it can be replaced atomically.
+	target, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(target.Name())
+
+	zipWriter := gzip.NewWriter(target)
+	// Keep Helm's header markers, but leave out the timestamp.
+	zipWriter.Header = gzip.Header{
+		Comment: zipReader.Header.Comment,
+		Extra:   zipReader.Header.Extra,
+		OS:      gzipOSUnknown,
+	}
+	tarWriter := tar.NewWriter(zipWriter)
+	for _, entry := range entries {
+		if err := tarWriter.WriteHeader(entry.header); err != nil {
+			target.Close()
+			return err
+		}
+		if _, err := tarWriter.Write(entry.data); err != nil {
+			target.Close()
+			return err
+		}
+	}
+	err = tarWriter.Close()
+	if err == nil {
+		err = zipWriter.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error writing %s", path)
+	}
+	if err := os.Chmod(target.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(target.Name(), path)
+}
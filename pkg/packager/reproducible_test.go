@@ -0,0 +1,152 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/git"
+)
+
+func TestPackager_sourceDate(t *testing.T) {
+	commitTime := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		epoch       string
+		git         Git
+		gitMetadata *git.Metadata
+		expected    time.Time
+		error       string
+	}{
+		{
+			name:     "source-date-epoch",
+			epoch:    "1680000000",
+			git:      &FakeGit{metadata: &git.Metadata{CommitTime: commitTime}},
+			expected: time.Unix(1680000000, 0).UTC(),
+		},
+		{
+			name:  "invalid-source-date-epoch",
+			epoch: "yesterday",
+			error: `invalid SOURCE_DATE_EPOCH "yesterday"`,
+		},
+		{
+			name:     "last-commit",
+			git:      &FakeGit{metadata: &git.Metadata{CommitTime: commitTime}},
+			expected: commitTime,
+		},
+		{
+			name:        "git-metadata-already-read",
+			gitMetadata: &git.Metadata{CommitTime: commitTime},
+			expected:    commitTime,
+		},
+		{
+			name:  "no-git",
+			error: "reproducible packages need SOURCE_DATE_EPOCH or a Git repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.epoch != "" {
+				t.Setenv(sourceDateEpochEnv, tt.epoch)
+			} else {
+				// Unset it for the test, t.Setenv restores it afterwards.
+				t.Setenv(sourceDateEpochEnv, "")
+				os.Unsetenv(sourceDateEpochEnv)
+			}
+			p := NewPackager(&config.Options{Reproducible: true}, nil, tt.git)
+			actual, err := p.sourceDate(context.Background(), tt.gitMetadata)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPackager_CreatePackagesReproducible(t *testing.T) {
+	t.Setenv(sourceDateEpochEnv, "1680000000")
+	dir := t.TempDir()
+	copyDir(t, "testdata/dependencies/lib", filepath.Join(dir, "lib"))
+	copyDir(t, "testdata/dependencies/sub", filepath.Join(dir, "sub"))
+
+	var digests []string
+	var packages []string
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			// Helm sets the current time on all files otherwise.
+			time.Sleep(time.Second)
+		}
+		packagePath := t.TempDir()
+		p := NewPackager(&config.Options{
+			PackagePath:  packagePath,
+			Reproducible: true,
+		}, []string{filepath.Join(dir, "sub")}, nil)
+		require.NoError(t, p.CreatePackages(context.Background()))
+
+		path := filepath.Join(packagePath, "sub-0.1.0.tgz")
+		digest, err := provenance.DigestFile(path)
+		require.NoError(t, err)
+		digests = append(digests, digest)
+		packages = append(packages, path)
+	}
+	assert.Equal(t, digests[0], digests[1])
+
+	file, err := os.Open(packages[0])
+	require.NoError(t, err)
+	defer file.Close()
+	zipReader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	assert.True(t, zipReader.Header.ModTime.IsZero())
+	assert.Equal(t, "Helm", zipReader.Header.Comment)
+
+	var names []string
+	tarReader := tar.NewReader(zipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		assert.Equal(t, time.Unix(1680000000, 0), header.ModTime, header.Name)
+		assert.Equal(t, 0, header.Uid, header.Name)
+		assert.Equal(t, "", header.Uname, header.Name)
+	}
+	assert.True(t, sort.StringsAreSorted(names), names)
+	assert.Contains(t, names, "sub/charts/lib/Chart.yaml")
+
+	// The package is still a valid chart.
+	ch, err := loader.Load(packages[0])
+	require.NoError(t, err)
+	assert.Equal(t, "sub", ch.Name())
+	assert.Len(t, ch.Dependencies(), 1)
+}
@@ -0,0 +1,224 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// SBOMExtension is appended to the path of a chart package to get the path of
+// its SBOM.
+const SBOMExtension = ".sbom.json"
+
+const (
+	sbomFormatCycloneDX = "cyclonedx"
+	sbomFormatSPDX      = "spdx"
+	sbomToolName        = "chart-releaser"
+)
+
+// writeSBOM writes the SBOM of the chart package in the given path in the
+// given format, listing the container images the chart deploys.
+func writeSBOM(format string, packagePath string, images []string, created time.Time) error {
+	ch, err := loader.Load(packagePath)
+	if err != nil {
+		return err
+	}
+	digest, err := provenance.DigestFile(packagePath)
+	if err != nil {
+		return err
+	}
+
+	var sbom interface{}
+	switch format {
+	case sbomFormatCycloneDX:
+		sbom = cycloneDXDocument(ch.Metadata, digest, images, created)
+	case sbomFormatSPDX:
+		sbom = spdxDocument(ch.Metadata, digest, images, created)
+	default:
+		return errors.Errorf("unknown SBOM format %q", format)
+	}
+	data, err := json.MarshalIndent(sbom, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(packagePath+SBOMExtension, append(data, '\n'), 0644)
+}
+
+type cycloneDXBOM struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name"`
+	Version string          `json:"version,omitempty"`
+	PURL    string          `json:"purl,omitempty"`
+	Hashes  []cycloneDXHash `json:"hashes,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// cycloneDXDocument returns a CycloneDX 1.4 SBOM describing the chart.
+func cycloneDXDocument(metadata *chart.Metadata, digest string, images []string, created time.Time) *cycloneDXBOM {
+	bom := &cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Name: sbomToolName}},
+			Component: cycloneDXComponent{
+				Type:    "application",
+				Name:    metadata.Name,
+				Version: metadata.Version,
+				Hashes:  []cycloneDXHash{{Algorithm: "SHA-256", Content: digest}},
+			},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	for _, image := range images {
+		ref := parseImage(image)
+		bom.Components = append(bom.Components, cycloneDXComponent{
+			Type:    "container",
+			Name:    image,
+			Version: ref.Version(),
+			PURL:    ref.PackageURL(),
+		})
+	}
+	return bom
+}
+
+type spdxDocumentData struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element        string `json:"spdxElementId"`
+	Type           string `json:"relationshipType"`
+	RelatedElement string `json:"relatedSpdxElement"`
+}
+
+// invalidSPDXIDCharacters matches the characters not allowed in SPDX
+// identifiers.
+var invalidSPDXIDCharacters = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+// spdxDocument returns an SPDX 2.3 SBOM describing the chart. The document
+// namespace is derived from the package digest, so that it is unique.
+func spdxDocument(metadata *chart.Metadata, digest string, images []string, created time.Time) *spdxDocumentData {
+	name := metadata.Name + "-" + metadata.Version
+	chartID := "SPDXRef-Chart-" + invalidSPDXIDCharacters.ReplaceAllString(metadata.Name, "-")
+	doc := &spdxDocumentData{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s/%s-%s", sbomToolName, name, digest),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomToolName},
+		},
+		Packages: []spdxPackage{{
+			Name:             metadata.Name,
+			SPDXID:           chartID,
+			VersionInfo:      metadata.Version,
+			DownloadLocation: "NOASSERTION",
+			Checksums:        []spdxChecksum{{Algorithm: "SHA256", Value: digest}},
+		}},
+		Relationships: []spdxRelationship{{
+			Element:        "SPDXRef-DOCUMENT",
+			Type:           "DESCRIBES",
+			RelatedElement: chartID,
+		}},
+	}
+	for i, image := range images {
+		ref := parseImage(image)
+		imageID := fmt.Sprintf("SPDXRef-Image-%d", i+1)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             image,
+			SPDXID:           imageID,
+			VersionInfo:      ref.Version(),
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{{
+				Category: "PACKAGE-MANAGER",
+				Type:     "purl",
+				Locator:  ref.PackageURL(),
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			Element:        chartID,
+			Type:           "DEPENDS_ON",
+			RelatedElement: imageID,
+		})
+	}
+	return doc
+}
@@ -0,0 +1,207 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+const (
+	testBusyboxImage = "registry.example.com:5000/tools/busybox@sha256:3fbc632167424a6d997e74f52b878d7cc478225cffac6bc977eedfe51c7f4e79"
+	testSidecarImage = "ghcr.io/example/sidecar:2.0.0"
+)
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected imageReference
+		purl     string
+	}{
+		{
+			image:    "nginx",
+			expected: imageReference{Repository: "nginx"},
+			purl:     "pkg:docker/nginx",
+		},
+		{
+			image:    "nginx:1.21.0",
+			expected: imageReference{Repository: "nginx", Tag: "1.21.0"},
+			purl:     "pkg:docker/nginx@1.21.0",
+		},
+		{
+			image:    "bitnami/redis:7.0",
+			expected: imageReference{Repository: "bitnami/redis", Tag: "7.0"},
+			purl:     "pkg:docker/bitnami/redis@7.0",
+		},
+		{
+			image:    testSidecarImage,
+			expected: imageReference{Registry: "ghcr.io", Repository: "example/sidecar", Tag: "2.0.0"},
+			purl:     "pkg:docker/example/sidecar@2.0.0?repository_url=ghcr.io",
+		},
+		{
+			image: testBusyboxImage,
+			expected: imageReference{
+				Registry:   "registry.example.com:5000",
+				Repository: "tools/busybox",
+				Digest:     "sha256:3fbc632167424a6d997e74f52b878d7cc478225cffac6bc977eedfe51c7f4e79",
+			},
+			purl: "pkg:docker/tools/busybox@sha256%3A3fbc632167424a6d997e74f52b878d7cc478225cffac6bc977eedfe51c7f4e79?repository_url=registry.example.com:5000",
+		},
+		{
+			image:    "localhost/app:dev",
+			expected: imageReference{Registry: "localhost", Repository: "app", Tag: "dev"},
+			purl:     "pkg:docker/app@dev?repository_url=localhost",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			actual := parseImage(tt.image)
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.purl, actual.PackageURL())
+		})
+	}
+}
+
+func TestChartImages(t *testing.T) {
+	packagePath := t.TempDir()
+	p := NewPackager(&config.Options{PackagePath: packagePath}, []string{"testdata/sbom/app"}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+	chartPackage := filepath.Join(packagePath, "app-0.1.0.tgz")
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+		error    string
+	}{
+		{
+			name:     "default-values",
+			expected: []string{"nginx:1.21.0", testBusyboxImage},
+		},
+		{
+			name:     "values-files",
+			patterns: []string{"ci/*-values.yaml"},
+			expected: []string{testSidecarImage, "nginx:1.21.0", testBusyboxImage},
+		},
+		{
+			name:     "invalid-pattern",
+			patterns: []string{"["},
+			error:    `invalid values file pattern "["`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := chartImages("testdata/sbom/app", chartPackage, tt.patterns)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, images)
+		})
+	}
+}
+
+func TestPackager_CreatePackagesWithSBOM(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         string
+		chart          string
+		expectedImages []string
+		annotation     string
+	}{
+		{
+			name:           "cyclonedx",
+			format:         "cyclonedx",
+			chart:          "app",
+			expectedImages: []string{testSidecarImage, "nginx:1.21.0", testBusyboxImage},
+			annotation: "- image: " + testSidecarImage + "\n  name: sidecar\n" +
+				"- image: nginx:1.21.0\n  name: nginx\n" +
+				"- image: " + testBusyboxImage + "\n  name: busybox\n",
+		},
+		{
+			name:           "spdx",
+			format:         "spdx",
+			chart:          "app",
+			expectedImages: []string{testSidecarImage, "nginx:1.21.0", testBusyboxImage},
+		},
+		{
+			name:           "keeps-existing-annotation",
+			format:         "cyclonedx",
+			chart:          "annotated",
+			expectedImages: []string{"busybox", "nginx:1.21.0"},
+			annotation:     "- name: web\n  image: nginx:1.21.0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := t.TempDir()
+			p := NewPackager(&config.Options{
+				PackagePath: packagePath,
+				SBOM:        tt.format,
+				SBOMValues:  []string{"ci/*-values.yaml"},
+			}, []string{filepath.Join("testdata/sbom", tt.chart)}, nil)
+			require.NoError(t, p.CreatePackages(context.Background()))
+
+			chartPackage := filepath.Join(packagePath, tt.chart+"-0.1.0.tgz")
+			ch, err := loader.Load(chartPackage)
+			require.NoError(t, err)
+			if tt.annotation != "" {
+				assert.Equal(t, tt.annotation, ch.Metadata.Annotations[imagesAnnotation])
+			}
+			digest, err := provenance.DigestFile(chartPackage)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(chartPackage + SBOMExtension)
+			require.NoError(t, err)
+			var images []string
+			switch tt.format {
+			case "cyclonedx":
+				var bom cycloneDXBOM
+				require.NoError(t, json.Unmarshal(data, &bom))
+				assert.Equal(t, "CycloneDX", bom.BOMFormat)
+				assert.Equal(t, tt.chart, bom.Metadata.Component.Name)
+				assert.Equal(t, digest, bom.Metadata.Component.Hashes[0].Content)
+				for _, component := range bom.Components {
+					assert.Equal(t, "container", component.Type)
+					images = append(images, component.Name)
+				}
+			case "spdx":
+				var doc spdxDocumentData
+				require.NoError(t, json.Unmarshal(data, &doc))
+				assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+				assert.Contains(t, doc.DocumentNamespace, digest)
+				require.NotEmpty(t, doc.Packages)
+				assert.Equal(t, tt.chart, doc.Packages[0].Name)
+				for _, pkg := range doc.Packages[1:] {
+					images = append(images, pkg.Name)
+				}
+				assert.Len(t, doc.Relationships, len(doc.Packages))
+			}
+			assert.Equal(t, tt.expectedImages, images)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// defaultMaxFileSize is the size in bytes above which files in a package are
+// reported unless configured otherwise.
+const defaultMaxFileSize = 1 << 20
+
+// Scan rules, as referenced in allowlist entries.
+const (
+	ScanRulePrivateKey = "private-key"
+	ScanRuleToken      = "token"
+	ScanRuleEnvFile    = "env-file"
+	ScanRuleGit        = "git"
+	ScanRuleOversized  = "oversized"
+)
+
+// secretPattern is a pattern of file content that is likely a secret.
+type secretPattern struct {
+	rule        string
+	description string
+	pattern     *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{ScanRulePrivateKey, "private key", regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{ScanRuleToken, "AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{ScanRuleToken, "GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{ScanRuleToken, "Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{ScanRuleToken, "Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{ScanRuleToken, "Stripe secret key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+}
+
+// privateKeyFiles are names of files which usually hold private keys.
+var privateKeyFiles = []string{"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519", "*.p12", "*.pfx", "*.jks"}
+
+// ScanFinding is a file in a chart package which is likely a secret or junk.
+type ScanFinding struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+func (f *ScanFinding) String() string {
+	return fmt.Sprintf("[ERROR] %s: %s (%s)", f.File, f.Message, f.Rule)
+}
+
+// ScanPackage inspects the files in the chart package in the given path,
+// including the packages of its dependencies, and returns the likely secrets,
+// environment files, Git directories and oversized files in it which are not
+// on the allowlist.
+func ScanPackage(scan *config.Scan, packagePath string) ([]*ScanFinding, error) {
+	file, err := os.Open(packagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	maxFileSize := scan.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSize
+	}
+	var findings []*ScanFinding
+	if err := scanArchive(file, "", maxFileSize, &findings); err != nil {
+		return nil, errors.Wrapf(err, "error scanning package %s", packagePath)
+	}
+
+	var reported []*ScanFinding
+	for _, finding := range findings {
+		if !isAllowlisted(scan.Allowlist, finding) {
+			reported = append(reported, finding)
+		}
+	}
+	return reported, nil
+}
+
+// scanArchive adds the findings in the gzipped tar archive read from r to
+// findings. File names are prefixed with prefix.
+func scanArchive(r io.Reader, prefix string, maxFileSize int64, findings *[]*ScanFinding) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	gitDirs := map[string]bool{}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := prefix + header.Name
+		add := func(rule string, message string) {
+			*findings = append(*findings, &ScanFinding{Rule: rule, File: name, Message: message})
+		}
+
+		// A Git directory is reported once instead of for every file in it.
+		if dir := gitDir(name); dir != "" {
+			if !gitDirs[dir] {
+				gitDirs[dir] = true
+				*findings = append(*findings, &ScanFinding{Rule: ScanRuleGit, File: dir, Message: "Git directory"})
+			}
+			continue
+		}
+
+		base := path.Base(name)
+		if base == ".env" || strings.HasPrefix(base, ".env.") {
+			add(ScanRuleEnvFile, "environment file")
+		}
+		for _, pattern := range privateKeyFiles {
+			if ok, _ := path.Match(pattern, base); ok {
+				add(ScanRulePrivateKey, "private key file")
+				break
+			}
+		}
+		if header.Size > maxFileSize {
+			add(ScanRuleOversized, fmt.Sprintf("file size %d bytes exceeds %d bytes", header.Size, maxFileSize))
+		}
+
+		if path.Ext(base) == ".tgz" && path.Base(path.Dir(name)) == "charts" {
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := scanArchive(bytes.NewReader(content), name+"/", maxFileSize, findings); err != nil {
+				return errors.Wrapf(err, "error scanning dependency package %s", name)
+			}
+			continue
+		}
+
+		// Content is read up to the size limit; oversized files are only
+		// searched in that part.
+		content, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+		if err != nil {
+			return err
+		}
+		for _, secret := range secretPatterns {
+			if secret.pattern.Match(content) {
+				add(secret.rule, "contains a "+secret.description)
+			}
+		}
+	}
+}
+
+// gitDir returns the path of the Git directory the named file is in, or ""
+// if it is in none.
+func gitDir(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts[:len(parts)-1] {
+		if part == ".git" {
+			return strings.Join(parts[:i+1], "/")
+		}
+	}
+	return ""
+}
+
+// isAllowlisted reports whether an allowlist entry matches the finding. Entry
+// paths are glob patterns matched against the file path in the package;
+// entries without a rule match all rules.
+func isAllowlisted(allowlist []config.ScanAllowlistEntry, finding *ScanFinding) bool {
+	for _, entry := range allowlist {
+		if entry.Rule != "" && entry.Rule != finding.Rule {
+			continue
+		}
+		if ok, _ := path.Match(entry.Path, finding.File); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scanPackage scans the chart package in the given path and writes the
+// findings to out. An error is returned if there are any.
+func scanPackage(out io.Writer, scan *config.Scan, packagePath string) error {
+	fmt.Fprintf(out, "==> Scanning %s\n", packagePath)
+	findings, err := ScanPackage(scan, packagePath)
+	if err != nil {
+		return err
+	}
+	for _, finding := range findings {
+		fmt.Fprintln(out, finding)
+	}
+	if len(findings) > 0 {
+		return errors.Errorf("package %s contains likely secrets or junk files not on the scan allowlist", packagePath)
+	}
+	return nil
+}
+
+// ScanPackages scans all chart packages in the given paths and writes the
+// findings to out, grouped by package. An error is returned if any package
+// has findings.
+func ScanPackages(out io.Writer, scan *config.Scan, packagePaths []string) error {
+	var failed []string
+	for _, packagePath := range packagePaths {
+		findings, err := ScanPackage(scan, packagePath)
+		if err != nil {
+			return err
+		}
+		if len(findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "==> Package %s contains likely secrets or junk files\n", packagePath)
+		for _, finding := range findings {
+			fmt.Fprintln(out, finding)
+		}
+		failed = append(failed, filepath.Base(packagePath))
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("packages contain likely secrets or junk files not on the scan allowlist: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+// Secrets are assembled so that the test source itself is not reported by
+// secret scanners.
+var (
+	testPrivateKey  = "-----BEGIN " + "RSA PRIVATE KEY-----\nMIIE\n-----END RSA PRIVATE KEY-----\n"
+	testGitHubToken = "ghp" + "_" + strings.Repeat("a1B2", 9)
+)
+
+// archive returns a gzipped tar archive with the given files.
+func archive(t *testing.T, files map[string]string) []byte {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, name := range names {
+		content := files[name]
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func TestScanPackage(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		scan     config.Scan
+		expected []string
+	}{
+		{
+			name: "clean",
+			files: map[string]string{
+				"app/Chart.yaml":             "name: app",
+				"app/templates/secret.yaml":  "password: {{ .Values.password }}",
+				"app/files/environment.conf": "LOG_LEVEL=debug",
+			},
+		},
+		{
+			name: "secrets",
+			files: map[string]string{
+				"app/Chart.yaml":       "name: app",
+				"app/files/tls.key":    testPrivateKey,
+				"app/files/id_ed25519": "key",
+				"app/values.yaml":      "token: " + testGitHubToken,
+			},
+			expected: []string{
+				"[ERROR] app/files/id_ed25519: private key file (private-key)",
+				"[ERROR] app/files/tls.key: contains a private key (private-key)",
+				"[ERROR] app/values.yaml: contains a GitHub token (token)",
+			},
+		},
+		{
+			name: "junk",
+			files: map[string]string{
+				"app/.env.production": "A=b",
+				"app/.git/HEAD":       "ref: refs/heads/main",
+				"app/.git/config":     "[core]",
+				"app/files/big.bin":   strings.Repeat("x", 11),
+			},
+			scan: config.Scan{MaxFileSize: 10},
+			expected: []string{
+				"[ERROR] app/.env.production: environment file (env-file)",
+				"[ERROR] app/.git: Git directory (git)",
+				"[ERROR] app/files/big.bin: file size 11 bytes exceeds 10 bytes (oversized)",
+			},
+		},
+		{
+			name: "allowlist",
+			files: map[string]string{
+				"app/files/tls.key":  testPrivateKey,
+				"app/files/.env":     "A=b",
+				"app/files/ca.key":   testPrivateKey,
+				"app/files/big.json": strings.Repeat("x", 101),
+			},
+			scan: config.Scan{
+				MaxFileSize: 100,
+				Allowlist: []config.ScanAllowlistEntry{
+					{Path: "app/files/*.key", Rule: "private-key", Reason: "test certificates"},
+					{Path: "app/files/.env", Rule: "oversized"},
+					{Path: "*/files/big.json"},
+				},
+			},
+			expected: []string{
+				"[ERROR] app/files/.env: environment file (env-file)",
+			},
+		},
+		{
+			name: "dependency-package",
+			files: map[string]string{
+				"app/Chart.yaml": "name: app",
+				"app/charts/dep-0.1.0.tgz": string(archive(t, map[string]string{
+					"dep/Chart.yaml": "name: dep",
+					"dep/files/.env": "A=b",
+				})),
+			},
+			expected: []string{
+				"[ERROR] app/charts/dep-0.1.0.tgz/dep/files/.env: environment file (env-file)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := filepath.Join(t.TempDir(), "app-0.1.0.tgz")
+			require.NoError(t, os.WriteFile(packagePath, archive(t, tt.files), 0644))
+
+			findings, err := ScanPackage(&tt.scan, packagePath)
+			require.NoError(t, err)
+			var actual []string
+			for _, finding := range findings {
+				actual = append(actual, finding.String())
+			}
+			sort.Strings(actual)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestScanPackages(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean-0.1.0.tgz")
+	leaky := filepath.Join(dir, "leaky-0.1.0.tgz")
+	require.NoError(t, os.WriteFile(clean, archive(t, map[string]string{"clean/Chart.yaml": "name: clean"}), 0644))
+	require.NoError(t, os.WriteFile(leaky, archive(t, map[string]string{"leaky/.env": "A=b"}), 0644))
+
+	var out bytes.Buffer
+	err := ScanPackages(&out, &config.Scan{}, []string{clean, leaky})
+	require.Error(t, err)
+	assert.Equal(t, "packages contain likely secrets or junk files not on the scan allowlist: leaky-0.1.0.tgz", err.Error())
+	assert.Equal(t, "==> Package "+leaky+" contains likely secrets or junk files\n"+
+		"[ERROR] leaky/.env: environment file (env-file)\n",
+		out.String())
+
+	require.NoError(t, ScanPackages(&out, &config.Scan{}, []string{clean}))
+}
+
+func TestPackager_CreatePackagesScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *config.Options
+		error   string
+	}{
+		{
+			name:    "env-file",
+			options: &config.Options{},
+			error:   "contains likely secrets or junk files not on the scan allowlist",
+		},
+		{
+			name: "allowlisted",
+			options: &config.Options{Scan: config.Scan{
+				Allowlist: []config.ScanAllowlistEntry{{Path: "leaky/files/.env"}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.PackagePath = t.TempDir()
+			p := NewPackager(tt.options, []string{"testdata/scan/leaky"}, nil)
+			err := p.CreatePackages(context.Background())
+			packageFile := filepath.Join(tt.options.PackagePath, "leaky-0.1.0.tgz")
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				assert.NoFileExists(t, packageFile)
+				return
+			}
+			require.NoError(t, err)
+			assert.FileExists(t, packageFile)
+		})
+	}
+}
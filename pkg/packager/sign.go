@@ -0,0 +1,190 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Helm's provenance package requires it
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// signer creates provenance files for chart packages. The key is only ever
+// held in memory.
+type signer struct {
+	mu        sync.Mutex
+	signatory *provenance.Signatory
+}
+
+// sign writes the provenance file for the chart package in the given path.
+func (s *signer) sign(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sig, err := s.signatory.ClearSign(path)
+	if err != nil {
+		return errors.Wrapf(err, "error signing %s", path)
+	}
+	return os.WriteFile(path+".prov", []byte(sig), 0644)
+}
+
+// newSigner loads the signing key selected with Key from the configured
+// source and decrypts it. The keyring is read from the environment variable
+// named by SigningKeyEnv, from stdin if KeyRing is '-', or from the KeyRing
+// file. It may be armored or binary.
+func (p *Packager) newSigner(stdin io.Reader) (*signer, error) {
+	if p.config.KeyRing == "-" && p.config.PassphraseFile == "-" {
+		return nil, errors.New("the keyring and the passphrase cannot both be read from stdin")
+	}
+
+	var keyring []byte
+	switch {
+	case p.config.SigningKeyEnv != "":
+		value, ok := os.LookupEnv(p.config.SigningKeyEnv)
+		if !ok || value == "" {
+			return nil, errors.Errorf("environment variable %s with the signing key is not set", p.config.SigningKeyEnv)
+		}
+		keyring = []byte(value)
+		// Keep the key from being passed on to child processes.
+		os.Unsetenv(p.config.SigningKeyEnv)
+	case p.config.KeyRing == "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading keyring from stdin")
+		}
+		keyring = data
+	default:
+		path, err := homedir.Expand(p.config.KeyRing)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error expanding keyring path %s", p.config.KeyRing)
+		}
+		if keyring, err = os.ReadFile(path); err != nil {
+			return nil, errors.Wrap(err, "error reading keyring")
+		}
+	}
+
+	ring, err := ReadKeyRing(keyring)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := findSigningKey(ring, p.config.Key)
+	if err != nil {
+		return nil, err
+	}
+	signatory := &provenance.Signatory{Entity: entity, KeyRing: ring}
+
+	passphrase, err := p.passphraseFetcher(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if err := signatory.DecryptKey(passphrase); err != nil {
+		return nil, errors.Wrap(err, "error decrypting signing key")
+	}
+	return &signer{signatory: signatory}, nil
+}
+
+// passphraseFetcher returns the passphrase from the environment variable named
+// by PassphraseEnv, or the first line of the PassphraseFile, which is stdin if
+// it is '-'.
+func (p *Packager) passphraseFetcher(stdin io.Reader) (provenance.PassphraseFetcher, error) {
+	var passphrase []byte
+	switch {
+	case p.config.PassphraseEnv != "":
+		value, ok := os.LookupEnv(p.config.PassphraseEnv)
+		if !ok {
+			return nil, errors.Errorf("environment variable %s with the passphrase is not set", p.config.PassphraseEnv)
+		}
+		passphrase = []byte(value)
+		os.Unsetenv(p.config.PassphraseEnv)
+	case p.config.PassphraseFile != "":
+		reader := stdin
+		if p.config.PassphraseFile != "-" {
+			file, err := os.Open(p.config.PassphraseFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "error reading passphrase file")
+			}
+			defer file.Close()
+			reader = file
+		}
+		line, _, err := bufio.NewReader(reader).ReadLine()
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading passphrase")
+		}
+		passphrase = line
+	default:
+		return func(name string) ([]byte, error) {
+			return nil, errors.Errorf("key %q is encrypted, but no passphrase is configured", name)
+		}, nil
+	}
+	return func(name string) ([]byte, error) {
+		return passphrase, nil
+	}, nil
+}
+
+// ReadKeyRing parses an armored or binary OpenPGP keyring.
+func ReadKeyRing(data []byte) (openpgp.EntityList, error) {
+	if ring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data)); err == nil {
+		return ring, nil
+	}
+	ring, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing keyring")
+	}
+	return ring, nil
+}
+
+// findSigningKey returns the private key whose identity is or, if unique,
+// contains id, like GnuPG and Helm do. If id is empty, the keyring must
+// contain exactly one private key.
+func findSigningKey(ring openpgp.EntityList, id string) (*openpgp.Entity, error) {
+	var candidates []*openpgp.Entity
+	for _, entity := range ring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if id == "" {
+			candidates = append(candidates, entity)
+			continue
+		}
+		for name := range entity.Identities {
+			if name == id {
+				return entity, nil
+			}
+			if strings.Contains(name, id) {
+				candidates = append(candidates, entity)
+				break
+			}
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) == 0 && id == "":
+		return nil, errors.New("no private key found in keyring")
+	case len(candidates) == 0:
+		return nil, errors.Errorf("no private key found for %q", id)
+	case id == "":
+		return nil, errors.New("more than one private key in keyring, select one with --key")
+	default:
+		return nil, errors.Errorf("more than one private key matches %q", id)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // Helm's provenance package requires it
+	"golang.org/x/crypto/openpgp/armor"
+	"helm.sh/helm/v3/pkg/provenance"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+)
+
+const testKeyName = "Chart Releaser Test Key <no-reply@example.com>"
+
+// armoredTestKeyring returns testdata/testkeyring.gpg, which holds an
+// encrypted private key, armored.
+func armoredTestKeyring(t *testing.T) string {
+	data, err := os.ReadFile("testdata/testkeyring.gpg")
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	w, err := armor.Encode(&buffer, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buffer.String()
+}
+
+// unencryptedKeyring returns an armored keyring with a new unencrypted private
+// key.
+func unencryptedKeyring(t *testing.T) string {
+	entity, err := openpgp.NewEntity("Unencrypted Key", "", "unencrypted@example.com", nil)
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	w, err := armor.Encode(&buffer, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(w, nil))
+	require.NoError(t, w.Close())
+	return buffer.String()
+}
+
+func TestPackager_newSigner(t *testing.T) {
+	passphrase, err := os.ReadFile("testdata/passphrase-file.txt")
+	require.NoError(t, err)
+	armored := armoredTestKeyring(t)
+
+	tests := []struct {
+		name    string
+		options *config.Options
+		env     map[string]string
+		stdin   string
+		error   string
+	}{
+		{
+			name:    "key-and-passphrase-from-env",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY", PassphraseEnv: "TEST_PASSPHRASE"},
+			env:     map[string]string{"TEST_SIGNING_KEY": armored, "TEST_PASSPHRASE": strings.TrimSpace(string(passphrase))},
+		},
+		{
+			name:    "key-from-stdin",
+			options: &config.Options{KeyRing: "-", Key: testKeyName, PassphraseEnv: "TEST_PASSPHRASE"},
+			env:     map[string]string{"TEST_PASSPHRASE": strings.TrimSpace(string(passphrase))},
+			stdin:   armored,
+		},
+		{
+			name:    "passphrase-from-stdin",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY", PassphraseFile: "-"},
+			env:     map[string]string{"TEST_SIGNING_KEY": armored},
+			stdin:   string(passphrase),
+		},
+		{
+			name:    "binary-keyring-file",
+			options: &config.Options{KeyRing: "testdata/testkeyring.gpg", Key: "Chart Releaser", PassphraseFile: "testdata/passphrase-file.txt"},
+		},
+		{
+			name:    "unencrypted-key",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY"},
+			env:     map[string]string{"TEST_SIGNING_KEY": unencryptedKeyring(t)},
+		},
+		{
+			name:    "both-from-stdin",
+			options: &config.Options{KeyRing: "-", PassphraseFile: "-"},
+			error:   "the keyring and the passphrase cannot both be read from stdin",
+		},
+		{
+			name:    "key-env-not-set",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY_MISSING"},
+			error:   "environment variable TEST_SIGNING_KEY_MISSING with the signing key is not set",
+		},
+		{
+			name:    "passphrase-env-not-set",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY", PassphraseEnv: "TEST_PASSPHRASE_MISSING"},
+			env:     map[string]string{"TEST_SIGNING_KEY": armored},
+			error:   "environment variable TEST_PASSPHRASE_MISSING with the passphrase is not set",
+		},
+		{
+			name:    "no-passphrase",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY"},
+			env:     map[string]string{"TEST_SIGNING_KEY": armored},
+			error:   "is encrypted, but no passphrase is configured",
+		},
+		{
+			name:    "wrong-passphrase",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY", PassphraseEnv: "TEST_PASSPHRASE"},
+			env:     map[string]string{"TEST_SIGNING_KEY": armored, "TEST_PASSPHRASE": "wrong"},
+			error:   "error decrypting signing key",
+		},
+		{
+			name:    "unknown-key",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY", Key: "Someone Else"},
+			env:     map[string]string{"TEST_SIGNING_KEY": armored},
+			error:   `no private key found for "Someone Else"`,
+		},
+		{
+			name:    "invalid-key",
+			options: &config.Options{SigningKeyEnv: "TEST_SIGNING_KEY"},
+			env:     map[string]string{"TEST_SIGNING_KEY": "not a key"},
+			error:   "error parsing keyring",
+		},
+		{
+			name:    "missing-keyring-in-home",
+			options: &config.Options{KeyRing: "~/.cr-test/missing.gpg"},
+			error:   "error reading keyring",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			p := NewPackager(tt.options, nil, nil)
+			signer, err := p.newSigner(strings.NewReader(tt.stdin))
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, signer)
+
+			// Secrets are removed from the environment once read.
+			for name := range tt.env {
+				_, ok := os.LookupEnv(name)
+				assert.False(t, ok, name)
+			}
+		})
+	}
+}
+
+func TestPackager_CreatePackagesSignedWithKeyFromEnv(t *testing.T) {
+	passphrase, err := os.ReadFile("testdata/passphrase-file.txt")
+	require.NoError(t, err)
+	t.Setenv("TEST_SIGNING_KEY", armoredTestKeyring(t))
+	t.Setenv("TEST_PASSPHRASE", strings.TrimSpace(string(passphrase)))
+
+	packagePath := t.TempDir()
+	p := NewPackager(&config.Options{
+		PackagePath:   packagePath,
+		Sign:          true,
+		SigningKeyEnv: "TEST_SIGNING_KEY",
+		PassphraseEnv: "TEST_PASSPHRASE",
+	}, []string{"testdata/test-chart"}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+
+	chartPath := filepath.Join(packagePath, "test-chart-0.1.0.tgz")
+	signatory, err := provenance.NewFromKeyring("testdata/testkeyring.gpg", "")
+	require.NoError(t, err)
+	verification, err := signatory.Verify(chartPath, chartPath+".prov")
+	require.NoError(t, err)
+	assert.Contains(t, verification.SignedBy.Identities, testKeyName)
+}
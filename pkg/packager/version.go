@@ -0,0 +1,87 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/tklauenberg/chart-releaser/pkg/git"
+)
+
+// VersionData is passed to the version and app version templates.
+type VersionData struct {
+	// Name, Version and AppVersion are the values from Chart.yaml.
+	Name       string
+	Version    string
+	AppVersion string
+	// Git describes the commit the charts are packaged from.
+	Git *git.Metadata
+}
+
+// usesGitMetadata reports whether the version templates need Git metadata.
+func (p *Packager) usesGitMetadata() bool {
+	return strings.Contains(p.config.Version, ".Git") || strings.Contains(p.config.AppVersion, ".Git")
+}
+
+// packageClient returns a copy of helmClient with the chart version and app
+// version set from the configured templates.
+func (p *Packager) packageClient(helmClient *action.Package, chartPath string, gitMetadata *git.Metadata) (*action.Package, error) {
+	client := *helmClient
+	if p.config.Version == "" && p.config.AppVersion == "" {
+		return &client, nil
+	}
+
+	metadata, err := chartutil.LoadChartfile(filepath.Join(chartPath, chartutil.ChartfileName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading chart %s", chartPath)
+	}
+	data := &VersionData{
+		Name:       metadata.Name,
+		Version:    metadata.Version,
+		AppVersion: metadata.AppVersion,
+		Git:        gitMetadata,
+	}
+
+	if client.Version, err = renderVersion("version", p.config.Version, data); err != nil {
+		return nil, err
+	}
+	if client.AppVersion, err = renderVersion("app-version", p.config.AppVersion, data); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func renderVersion(name string, text string, data *VersionData) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid %s template", name)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", errors.Wrapf(err, "error rendering %s template", name)
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
@@ -0,0 +1,120 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packager
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/git"
+)
+
+type FakeGit struct {
+	metadata *git.Metadata
+}
+
+func (f *FakeGit) HeadMetadata(ctx context.Context, workingDir string) (*git.Metadata, error) {
+	return f.metadata, nil
+}
+
+var testGitMetadata = &git.Metadata{
+	Commit:          "abc123def4567890abc123def4567890abc123de",
+	ShortCommit:     "abc123d",
+	Branch:          "main",
+	Tag:             "test-chart-0.1.0",
+	CommitsSinceTag: 12,
+}
+
+func TestPackager_packageClient(t *testing.T) {
+	tests := []struct {
+		name               string
+		version            string
+		appVersion         string
+		expectedVersion    string
+		expectedAppVersion string
+		error              bool
+	}{
+		{
+			name: "no-overrides",
+		},
+		{
+			name:            "literal-version",
+			version:         "1.3.0",
+			expectedVersion: "1.3.0",
+		},
+		{
+			name:               "git-metadata",
+			version:            "{{ .Version }}-dev.{{ .Git.CommitsSinceTag }}+{{ .Git.ShortCommit }}",
+			appVersion:         "{{ .AppVersion }}-{{ .Git.Branch }}",
+			expectedVersion:    "0.1.0-dev.12+abc123d",
+			expectedAppVersion: "1.16.0-main",
+		},
+		{
+			name:            "describe",
+			version:         "{{ .Git.Describe }}",
+			expectedVersion: "test-chart-0.1.0-12-gabc123d",
+		},
+		{
+			name:    "invalid-template",
+			version: "{{ .Version ",
+			error:   true,
+		},
+		{
+			name:    "unknown-field",
+			version: "{{ .Git.Hash }}",
+			error:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPackager(&config.Options{Version: tt.version, AppVersion: tt.appVersion}, nil, nil)
+			client, err := p.packageClient(action.NewPackage(), "testdata/test-chart", testGitMetadata)
+			if tt.error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedVersion, client.Version)
+			assert.Equal(t, tt.expectedAppVersion, client.AppVersion)
+		})
+	}
+}
+
+func TestPackager_CreatePackagesWithVersion(t *testing.T) {
+	packagePath := t.TempDir()
+	p := NewPackager(&config.Options{
+		PackagePath: packagePath,
+		Version:     "{{ .Version }}-dev.{{ .Git.CommitsSinceTag }}+{{ .Git.ShortCommit }}",
+		AppVersion:  "sha-{{ .Git.ShortCommit }}",
+	}, []string{"testdata/test-chart"}, &FakeGit{metadata: testGitMetadata})
+	require.NoError(t, p.CreatePackages(context.Background()))
+
+	ch, err := loader.Load(filepath.Join(packagePath, "test-chart-0.1.0-dev.12+abc123d.tgz"))
+	require.NoError(t, err)
+	assert.Equal(t, "0.1.0-dev.12+abc123d", ch.Metadata.Version)
+	assert.Equal(t, "sha-abc123d", ch.Metadata.AppVersion)
+}
+
+func TestPackager_CreatePackagesWithInvalidVersion(t *testing.T) {
+	p := NewPackager(&config.Options{PackagePath: t.TempDir(), Version: "nightly"}, []string{"testdata/test-chart"}, nil)
+	require.Error(t, p.CreatePackages(context.Background()))
+}
@@ -19,7 +19,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
@@ -41,6 +40,7 @@ import (
 	"helm.sh/helm/v3/pkg/repo"
 
 	"github.com/tklauenberg/chart-releaser/pkg/github"
+	"github.com/tklauenberg/chart-releaser/pkg/packager"
 )
 
 // GitHub contains the functions necessary for interacting with GitHub release
@@ -49,7 +49,11 @@ type GitHub interface {
 	CreateRelease(ctx context.Context, input *github.Release) error
 	GetRelease(ctx context.Context, tag string) (*github.Release, error)
 	GetReleases(ctx context.Context) ([]*github.Release, error)
-	CreatePullRequest(owner string, repo string, message string, head string, base string) (string, error)
+	GetPullRequest(ctx context.Context, owner string, repo string, head string, base string) (*github.PullRequest, error)
+	CreatePullRequest(ctx context.Context, owner string, repo string, message string, head string, base string) (*github.PullRequest, error)
+	UpdatePullRequest(ctx context.Context, owner string, repo string, number int, message string) error
+	ConfigurePullRequest(ctx context.Context, owner string, repo string, pr *github.PullRequest, opts *github.PullRequestOptions) error
+	DownloadReleaseAsset(ctx context.Context, id int64) (io.ReadCloser, error)
 }
 
 type HTTPClient interface {
@@ -57,22 +61,37 @@ type HTTPClient interface {
 }
 
 type Git interface {
-	AddWorktree(workingDir string, committish string) (string, error)
-	RemoveWorktree(workingDir string, path string) error
-	Add(workingDir string, args ...string) error
-	Commit(workingDir string, message string) error
-	Push(workingDir string, args ...string) error
-	GetPushURL(remote string, token string) (string, error)
+	AddWorktree(ctx context.Context, workingDir string, committish string) (string, error)
+	RemoveWorktree(ctx context.Context, workingDir string, path string) error
+	Add(ctx context.Context, workingDir string, args ...string) error
+	Commit(ctx context.Context, workingDir string, message string) error
+	Push(ctx context.Context, workingDir string, args ...string) error
+	GetPushURL(ctx context.Context, remote string) (string, error)
 }
 
 type DefaultHTTPClient struct{}
 
-var letters = []rune("abcdefghijklmnopqrstuvwxyz0123456789")
-
 const chartAssetFileExtension = ".tgz"
 
-func init() {
-	rand.New(rand.NewSource(time.Now().UnixNano())) // nolint: gosec
+// defaultCommitMessageTemplate is used when no commit message template is configured.
+const defaultCommitMessageTemplate = `Update {{ .IndexPath }}
+{{- if .Charts }}
+
+Added chart versions:
+{{- range .Charts }}
+- {{ .Name }} {{ .Version }}
+{{- end }}
+{{- end }}
+`
+
+// pullRequestTemplate is used for the title and body of the index pull request.
+const pullRequestTemplate = defaultCommitMessageTemplate
+
+// IndexUpdate describes the chart versions added to the index. It is the data
+// passed to the commit message template.
+type IndexUpdate struct {
+	IndexPath string
+	Charts    []*chart.Metadata
 }
 
 func (c *DefaultHTTPClient) Get(url string) (resp *http.Response, err error) {
@@ -94,13 +113,14 @@ func NewReleaser(config *config.Options, github GitHub, git Git) *Releaser {
 }
 
 // UpdateIndexFile updates the index.yaml file for a given Git repo
-func (r *Releaser) UpdateIndexFile() (bool, error) {
+func (r *Releaser) UpdateIndexFile(ctx context.Context) (bool, error) {
 	var worktree = ""
 	indexYamlPath := filepath.Join(worktree, "index.yaml")
 
 	var indexFile = repo.NewIndexFile()
+	previousIndexFile := r.loadPreviousIndexFile(indexYamlPath)
 
-	releases, err := r.github.GetReleases(context.TODO())
+	releases, err := r.github.GetReleases(ctx)
 
 	if err != nil {
 		return false, err
@@ -111,6 +131,7 @@ func (r *Releaser) UpdateIndexFile() (bool, error) {
 	}
 
 	var update bool
+	indexUpdate := &IndexUpdate{IndexPath: r.config.PagesIndexPath}
 	for _, release := range releases {
 		for _, asset := range release.Assets {
 			downloadURL, _ := url.Parse(asset.URL)
@@ -124,9 +145,13 @@ func (r *Releaser) UpdateIndexFile() (bool, error) {
 			packageName, packageVersion := tagParts[0], tagParts[1]
 			fmt.Printf("Found %s-%s.tgz\n", packageName, packageVersion)
 			if _, err := indexFile.Get(packageName, packageVersion); err != nil {
-				if err := r.addToIndexFile(indexFile, downloadURL.String()); err != nil {
+				metadata, err := r.addToIndexFile(ctx, indexFile, downloadURL.String())
+				if err != nil {
 					return false, err
 				}
+				if previousIndexFile == nil || !previousIndexFile.Has(metadata.Name, metadata.Version) {
+					indexUpdate.Charts = append(indexUpdate.Charts, metadata)
+				}
 				update = true
 				break
 			}
@@ -160,49 +185,94 @@ func (r *Releaser) UpdateIndexFile() (bool, error) {
 	if err := copyFile(r.config.IndexPath, indexYamlPath); err != nil {
 		return false, err
 	}
-	if err := r.git.Add(worktree, indexYamlPath); err != nil {
+	if err := r.git.Add(ctx, worktree, indexYamlPath); err != nil {
+		return false, err
+	}
+	commitMessage, err := r.renderIndexUpdate(r.config.CommitMessageTemplate, indexUpdate)
+	if err != nil {
 		return false, err
 	}
-	if err := r.git.Commit(worktree, fmt.Sprintf("Update %s", r.config.PagesIndexPath)); err != nil {
+	if err := r.git.Commit(ctx, worktree, commitMessage); err != nil {
 		return false, err
 	}
 
-	pushURL, err := r.git.GetPushURL(r.config.Remote, r.config.Token)
+	pushURL, err := r.git.GetPushURL(ctx, r.config.Remote)
 	if err != nil {
 		return false, err
 	}
 
 	if r.config.Push {
 		fmt.Printf("Pushing to branch %q\n", r.config.PagesBranch)
-		if err := r.git.Push(worktree, pushURL, "HEAD:refs/heads/"+r.config.PagesBranch); err != nil {
+		if err := r.git.Push(ctx, worktree, pushURL, "HEAD:refs/heads/"+r.config.PagesBranch); err != nil {
 			return false, err
 		}
 	} else if r.config.PR {
-		branch := fmt.Sprintf("chart-releaser-%s", randomString(16))
+		branch := r.config.PRBranch
 
+		// Force-push, so that an open pull request from the branch is updated
+		// with the latest index instead of opening another one.
 		fmt.Printf("Pushing to branch %q\n", branch)
-		if err := r.git.Push(worktree, pushURL, "HEAD:refs/heads/"+branch); err != nil {
+		if err := r.git.Push(ctx, worktree, pushURL, "+HEAD:refs/heads/"+branch); err != nil {
 			return false, err
 		}
-		fmt.Printf("Creating pull request against branch %q\n", r.config.PagesBranch)
-		prURL, err := r.github.CreatePullRequest(r.config.Owner, r.config.GitRepo, "Update index.yaml", branch, r.config.PagesBranch)
-		if err != nil {
+		if err := r.createOrUpdatePullRequest(ctx, branch, indexUpdate); err != nil {
 			return false, err
 		}
-		fmt.Println("Pull request created:", prURL)
 	}
 
 	return true, nil
 }
 
+// createOrUpdatePullRequest opens a pull request from branch against the pages
+// branch, or updates the one that is already open.
+func (r *Releaser) createOrUpdatePullRequest(ctx context.Context, branch string, update *IndexUpdate) error {
+	message, err := r.renderIndexUpdate(pullRequestTemplate, update)
+	if err != nil {
+		return err
+	}
+
+	pr, err := r.github.GetPullRequest(ctx, r.config.Owner, r.config.GitRepo, branch, r.config.PagesBranch)
+	if err != nil {
+		return errors.Wrap(err, "failed to look up existing pull request")
+	}
+	if pr != nil {
+		fmt.Printf("Updating pull request #%d against branch %q\n", pr.Number, r.config.PagesBranch)
+		if err := r.github.UpdatePullRequest(ctx, r.config.Owner, r.config.GitRepo, pr.Number, message); err != nil {
+			return err
+		}
+		fmt.Println("Pull request updated:", pr.URL)
+	} else {
+		fmt.Printf("Creating pull request against branch %q\n", r.config.PagesBranch)
+		pr, err = r.github.CreatePullRequest(ctx, r.config.Owner, r.config.GitRepo, message, branch, r.config.PagesBranch)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Pull request created:", pr.URL)
+	}
+
+	return r.github.ConfigurePullRequest(ctx, r.config.Owner, r.config.GitRepo, pr, &github.PullRequestOptions{
+		Labels:        r.config.PRLabels,
+		Reviewers:     r.config.PRReviewers,
+		TeamReviewers: r.config.PRTeamReviewers,
+		AutoMerge:     r.config.PRAutoMerge,
+		MergeMethod:   r.config.PRMergeMethod,
+	})
+}
+
 func (r *Releaser) computeReleaseName(chart *chart.Chart) (string, error) {
-	tmpl, err := template.New("gotpl").Parse(r.config.ReleaseNameTemplate)
+	return ReleaseName(r.config.ReleaseNameTemplate, chart.Metadata)
+}
+
+// ReleaseName renders the release name template for the given chart metadata.
+// The release name is also the name of the tag the release creates.
+func ReleaseName(text string, metadata *chart.Metadata) (string, error) {
+	tmpl, err := template.New("gotpl").Parse(text)
 	if err != nil {
 		return "", err
 	}
 
 	var buffer bytes.Buffer
-	if err := tmpl.Execute(&buffer, chart.Metadata); err != nil {
+	if err := tmpl.Execute(&buffer, metadata); err != nil {
 		return "", err
 	}
 
@@ -210,6 +280,38 @@ func (r *Releaser) computeReleaseName(chart *chart.Chart) (string, error) {
 	return releaseName, nil
 }
 
+// loadPreviousIndexFile loads the index file that is about to be replaced, if
+// there is one, to find out which chart versions are new.
+func (r *Releaser) loadPreviousIndexFile(path string) *repo.IndexFile {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	indexFile, err := repo.LoadIndexFile(path)
+	if err != nil {
+		fmt.Printf("Ignoring existing index %s: %s\n", path, err)
+		return nil
+	}
+	return indexFile
+}
+
+// renderIndexUpdate renders a commit or pull request message. The default
+// template is used if text is empty.
+func (r *Releaser) renderIndexUpdate(text string, update *IndexUpdate) (string, error) {
+	if text == "" {
+		text = defaultCommitMessageTemplate
+	}
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid message template")
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, update); err != nil {
+		return "", errors.Wrap(err, "failed to render message")
+	}
+	return buffer.String(), nil
+}
+
 func (r *Releaser) getReleaseNotes(chart *chart.Chart) string {
 	if r.config.ReleaseNotesFile != "" {
 		for _, f := range chart.Files {
@@ -227,7 +329,9 @@ func (r *Releaser) splitPackageNameAndVersion(pkg string) []string {
 	return []string{pkg[0:delimIndex], pkg[delimIndex+1:]}
 }
 
-func (r *Releaser) DownloadFile(urlStr string) (string, error) {
+// DownloadFile downloads the given URL into the package path. A partially
+// downloaded file is removed if the download fails or is cancelled.
+func (r *Releaser) DownloadFile(ctx context.Context, urlStr string) (string, error) {
 	filePath := filepath.Join(r.config.PackagePath, filepath.Base(urlStr))
 
 	// Create the directory if it doesn't exist
@@ -242,13 +346,6 @@ func (r *Releaser) DownloadFile(urlStr string) (string, error) {
 		return filePath, nil
 	}
 
-	// Create the output file
-	file, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("error creating file: %w", err)
-	}
-	defer file.Close()
-
 	// Validate and parse the URL
 	parsedURL, err := url.ParseRequestURI(urlStr)
 	if err != nil {
@@ -256,7 +353,11 @@ func (r *Releaser) DownloadFile(urlStr string) (string, error) {
 	}
 
 	// Send an HTTP GET request
-	response, err := http.Get(parsedURL.String())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return "", fmt.Errorf("error sending request: %w", err)
 	}
@@ -267,33 +368,43 @@ func (r *Releaser) DownloadFile(urlStr string) (string, error) {
 		return "", fmt.Errorf("error response: %s", response.Status)
 	}
 
+	// Create the output file
+	file, err := os.Create(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error creating file: %w", err)
+	}
+
 	// Copy the response body to the file
 	_, err = io.Copy(file, response.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(filePath)
 		return "", fmt.Errorf("error saving file: %w", err)
 	}
 
 	return filePath, nil
 }
 
-func (r *Releaser) addToIndexFile(indexFile *repo.IndexFile, url string) error {
-	arch, err := r.DownloadFile(url)
+func (r *Releaser) addToIndexFile(ctx context.Context, indexFile *repo.IndexFile, url string) (*chart.Metadata, error) {
+	arch, err := r.DownloadFile(ctx, url)
 
 	if err != nil {
-		return errors.Wrapf(err, "err in download")
+		return nil, errors.Wrapf(err, "err in download")
 	}
 
 	// extract chart metadata
 	fmt.Printf("Extracting chart metadata from %s\n", arch)
 	c, err := loader.LoadFile(arch)
 	if err != nil {
-		return errors.Wrapf(err, "%s is not a helm chart package", arch)
+		return nil, errors.Wrapf(err, "%s is not a helm chart package", arch)
 	}
 	// calculate hash
 	fmt.Printf("Calculating Hash for %s\n", arch)
 	hash, err := provenance.DigestFile(arch)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// remove url name from url as helm's index library
@@ -303,25 +414,64 @@ func (r *Releaser) addToIndexFile(indexFile *repo.IndexFile, url string) error {
 	s = s[:len(s)-1]
 
 	// Add to index
-	return indexFile.MustAdd(c.Metadata, filepath.Base(arch), strings.Join(s, "/"), hash)
+	return c.Metadata, indexFile.MustAdd(c.Metadata, filepath.Base(arch), strings.Join(s, "/"), hash)
 }
 
 // CreateReleases finds and uploads Helm chart packages to GitHub
-func (r *Releaser) CreateReleases() error {
+func (r *Releaser) CreateReleases(ctx context.Context) error {
 	packages, err := r.getListOfPackages(r.config.PackagePath)
 	if err != nil {
 		return err
 	}
 
 	if len(packages) == 0 {
+		if r.config.Manifest != "" {
+			return errors.Errorf("no charts found in manifest %s", r.config.Manifest)
+		}
 		return errors.Errorf("no charts found at %s", r.config.PackagePath)
 	}
 
-	for _, p := range packages {
-		ch, err := loader.LoadFile(p)
-		if err != nil {
+	// All packages are checked against the metadata policy, scanned and
+	// their versions, and values if configured, checked against the released
+	// ones before any release is created.
+	charts := make([]*chart.Chart, len(packages))
+	metadata := make([]*chart.Metadata, len(packages))
+	for i, p := range packages {
+		if charts[i], err = loader.LoadFile(p); err != nil {
 			return err
 		}
+		metadata[i] = charts[i].Metadata
+	}
+	if err := packager.EnforcePolicy(os.Stdout, &r.config.Policy, metadata); err != nil {
+		return err
+	}
+	if err := packager.ScanPackages(os.Stdout, &r.config.Scan, packages); err != nil {
+		return err
+	}
+	names := []string{}
+	for _, m := range metadata {
+		if !contains(names, m.Name) {
+			names = append(names, m.Name)
+		}
+	}
+	released, err := r.releasedVersions(ctx, names)
+	if err != nil {
+		return err
+	}
+	if err := checkVersions(os.Stdout, released, metadata, r.config.AllowBackport, r.config.SkipExisting); err != nil {
+		return err
+	}
+	if r.config.CheckValues {
+		if err := r.checkValues(ctx, os.Stdout, released, charts); err != nil {
+			return err
+		}
+	}
+
+	for i, p := range packages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ch := charts[i]
 		releaseName, err := r.computeReleaseName(ch)
 		if err != nil {
 			return err
@@ -342,13 +492,17 @@ func (r *Releaser) CreateReleases() error {
 			asset := &github.Asset{Path: provFile}
 			release.Assets = append(release.Assets, asset)
 		}
+		sbomFile := p + packager.SBOMExtension
+		if _, err := os.Stat(sbomFile); err == nil {
+			release.Assets = append(release.Assets, &github.Asset{Path: sbomFile})
+		}
 		if r.config.SkipExisting {
-			existingRelease, _ := r.github.GetRelease(context.TODO(), releaseName)
+			existingRelease, _ := r.github.GetRelease(ctx, releaseName)
 			if existingRelease != nil {
 				continue
 			}
 		}
-		if err := r.github.CreateRelease(context.TODO(), release); err != nil {
+		if err := r.github.CreateRelease(ctx, release); err != nil {
 			return errors.Wrapf(err, "error creating GitHub release %s", releaseName)
 		}
 	}
@@ -356,7 +510,12 @@ func (r *Releaser) CreateReleases() error {
 	return nil
 }
 
+// getListOfPackages returns the packages listed in the configured manifest, or
+// else all packages in dir.
 func (r *Releaser) getListOfPackages(dir string) ([]string, error) {
+	if r.config.Manifest != "" {
+		return packager.ReadManifest(r.config.Manifest)
+	}
 	return filepath.Glob(filepath.Join(dir, "*.tgz"))
 }
 
@@ -376,11 +535,3 @@ func copyFile(srcFile string, dstFile string) error {
 	_, err = io.Copy(destination, source)
 	return err
 }
-
-func randomString(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))] // nolint: gosec
-	}
-	return string(b)
-}
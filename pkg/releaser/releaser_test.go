@@ -16,25 +16,35 @@ package releaser
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
 	"github.com/tklauenberg/chart-releaser/pkg/github"
 )
 
 type FakeGitHub struct {
 	mock.Mock
 	release *github.Release
+	// releases are returned by GetReleases if set.
+	releases []*github.Release
+	// assetFiles are the files of release assets by asset ID.
+	assetFiles map[int64]string
 }
 
 type FakeGit struct {
 	indexFile string
 }
 
-func (f *FakeGit) AddWorktree(workingDir string, committish string) (string, error) {
+func (f *FakeGit) AddWorktree(ctx context.Context, workingDir string, committish string) (string, error) {
 	dir, err := os.MkdirTemp("", "chart-releaser-")
 	if err != nil {
 		return "", err
@@ -46,23 +56,23 @@ func (f *FakeGit) AddWorktree(workingDir string, committish string) (string, err
 	return dir, copyFile(f.indexFile, filepath.Join(dir, "index.yaml"))
 }
 
-func (f *FakeGit) RemoveWorktree(workingDir string, path string) error {
+func (f *FakeGit) RemoveWorktree(ctx context.Context, workingDir string, path string) error {
 	return nil
 }
 
-func (f *FakeGit) Add(workingDir string, args ...string) error {
+func (f *FakeGit) Add(ctx context.Context, workingDir string, args ...string) error {
 	panic("implement me")
 }
 
-func (f *FakeGit) Commit(workingDir string, message string) error {
+func (f *FakeGit) Commit(ctx context.Context, workingDir string, message string) error {
 	panic("implement me")
 }
 
-func (f *FakeGit) Push(workingDir string, args ...string) error {
+func (f *FakeGit) Push(ctx context.Context, workingDir string, args ...string) error {
 	panic("implement me")
 }
 
-func (f *FakeGit) GetPushURL(remote string, token string) (string, error) {
+func (f *FakeGit) GetPushURL(ctx context.Context, remote string) (string, error) {
 	panic("implement me")
 }
 
@@ -91,6 +101,9 @@ func (f *FakeGitHub) GetRelease(ctx context.Context, tag string) (*github.Releas
 }
 
 func (f *FakeGitHub) GetReleases(ctx context.Context) ([]*github.Release, error) {
+	if f.releases != nil {
+		return f.releases, nil
+	}
 	releases := []*github.Release{
 		{
 			Name:        "testdata/release-packages/test-chart-0.1.0",
@@ -109,9 +122,33 @@ func (f *FakeGitHub) GetReleases(ctx context.Context) ([]*github.Release, error)
 	return releases, nil
 }
 
-func (f *FakeGitHub) CreatePullRequest(owner string, repo string, message string, head string, base string) (string, error) {
+func (f *FakeGitHub) DownloadReleaseAsset(ctx context.Context, id int64) (io.ReadCloser, error) {
+	path, ok := f.assetFiles[id]
+	if !ok {
+		return nil, fmt.Errorf("no release asset %d", id)
+	}
+	return os.Open(path)
+}
+
+func (f *FakeGitHub) GetPullRequest(ctx context.Context, owner string, repo string, head string, base string) (*github.PullRequest, error) {
+	args := f.Called(owner, repo, head, base)
+	pr, _ := args.Get(0).(*github.PullRequest)
+	return pr, args.Error(1)
+}
+
+func (f *FakeGitHub) CreatePullRequest(ctx context.Context, owner string, repo string, message string, head string, base string) (*github.PullRequest, error) {
 	f.Called(owner, repo, message, head, base)
-	return "https://github.com/owner/repo/pull/42", nil
+	return &github.PullRequest{Number: 42, URL: "https://github.com/owner/repo/pull/42"}, nil
+}
+
+func (f *FakeGitHub) UpdatePullRequest(ctx context.Context, owner string, repo string, number int, message string) error {
+	f.Called(owner, repo, number, message)
+	return nil
+}
+
+func (f *FakeGitHub) ConfigurePullRequest(ctx context.Context, owner string, repo string, pr *github.PullRequest, opts *github.PullRequestOptions) error {
+	f.Called(owner, repo, pr, opts)
+	return nil
 }
 
 func TestReleaser_splitPackageNameAndVersion(t *testing.T) {
@@ -150,3 +187,197 @@ func TestReleaser_splitPackageNameAndVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestReleaser_renderIndexUpdate(t *testing.T) {
+	charts := []*chart.Metadata{
+		{Name: "foo", Version: "1.2.3"},
+		{Name: "bar", Version: "0.1.0"},
+	}
+	tests := []struct {
+		name     string
+		template string
+		charts   []*chart.Metadata
+		expected string
+		error    bool
+	}{
+		{
+			name:     "default-template",
+			charts:   charts,
+			expected: "Update index.yaml\n\nAdded chart versions:\n- foo 1.2.3\n- bar 0.1.0\n",
+		},
+		{
+			name:     "default-template-no-charts",
+			expected: "Update index.yaml\n",
+		},
+		{
+			name:     "custom-template",
+			template: "chore: release {{ range $i, $c := .Charts }}{{ if $i }}, {{ end }}{{ $c.Name }}@{{ $c.Version }}{{ end }}",
+			charts:   charts,
+			expected: "chore: release foo@1.2.3, bar@0.1.0",
+		},
+		{
+			name:     "invalid-template",
+			template: "{{ .Charts",
+			error:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Releaser{}
+			actual, err := r.renderIndexUpdate(tt.template, &IndexUpdate{IndexPath: "index.yaml", Charts: tt.charts})
+			if tt.error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestReleaser_createOrUpdatePullRequest(t *testing.T) {
+	update := &IndexUpdate{
+		IndexPath: "index.yaml",
+		Charts:    []*chart.Metadata{{Name: "foo", Version: "1.2.3"}},
+	}
+	message := "Update index.yaml\n\nAdded chart versions:\n- foo 1.2.3\n"
+	existing := &github.PullRequest{Number: 7, URL: "https://github.com/owner/repo/pull/7"}
+	created := &github.PullRequest{Number: 42, URL: "https://github.com/owner/repo/pull/42"}
+
+	tests := []struct {
+		name     string
+		existing *github.PullRequest
+		expected *github.PullRequest
+	}{
+		{
+			name:     "create",
+			expected: created,
+		},
+		{
+			name:     "update",
+			existing: existing,
+			expected: existing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &github.PullRequestOptions{
+				Labels:      []string{"charts"},
+				Reviewers:   []string{"octocat"},
+				AutoMerge:   true,
+				MergeMethod: "squash",
+			}
+			fakeGitHub := new(FakeGitHub)
+			fakeGitHub.On("GetPullRequest", "owner", "repo", "chart-releaser-index", "gh-pages").Return(tt.existing, nil)
+			if tt.existing != nil {
+				fakeGitHub.On("UpdatePullRequest", "owner", "repo", tt.existing.Number, message).Return()
+			} else {
+				fakeGitHub.On("CreatePullRequest", "owner", "repo", message, "chart-releaser-index", "gh-pages").Return()
+			}
+			fakeGitHub.On("ConfigurePullRequest", "owner", "repo", tt.expected, opts).Return()
+
+			r := &Releaser{
+				config: &config.Options{
+					Owner:         "owner",
+					GitRepo:       "repo",
+					PagesBranch:   "gh-pages",
+					PRLabels:      opts.Labels,
+					PRReviewers:   opts.Reviewers,
+					PRAutoMerge:   opts.AutoMerge,
+					PRMergeMethod: opts.MergeMethod,
+				},
+				github: fakeGitHub,
+			}
+			assert.NoError(t, r.createOrUpdatePullRequest(context.Background(), "chart-releaser-index", update))
+			fakeGitHub.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReleaser_CreateReleases(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		expected []string
+	}{
+		{
+			name:     "package-only",
+			expected: []string{"test-chart-0.1.0.tgz"},
+		},
+		{
+			name:     "provenance-and-sbom",
+			files:    []string{"test-chart-0.1.0.tgz.prov", "test-chart-0.1.0.tgz.sbom.json"},
+			expected: []string{"test-chart-0.1.0.tgz", "test-chart-0.1.0.tgz.prov", "test-chart-0.1.0.tgz.sbom.json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := t.TempDir()
+			require.NoError(t, copyFile("testdata/release-packages/test-chart-0.1.0.tgz", filepath.Join(packagePath, "test-chart-0.1.0.tgz")))
+			for _, file := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(packagePath, file), []byte("{}"), 0644))
+			}
+
+			fakeGitHub := &FakeGitHub{releases: []*github.Release{}}
+			fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return()
+			r := &Releaser{
+				config: &config.Options{
+					PackagePath:         packagePath,
+					ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}",
+				},
+				github: fakeGitHub,
+			}
+			require.NoError(t, r.CreateReleases(context.Background()))
+			fakeGitHub.AssertExpectations(t)
+
+			var assets []string
+			for _, asset := range fakeGitHub.release.Assets {
+				assets = append(assets, filepath.Base(asset.Path))
+			}
+			assert.Equal(t, "test-chart-0.1.0", fakeGitHub.release.Name)
+			assert.Equal(t, tt.expected, assets)
+		})
+	}
+}
+
+func TestReleaser_CreateReleasesPolicy(t *testing.T) {
+	packagePath := t.TempDir()
+	require.NoError(t, copyFile("testdata/release-packages/test-chart-0.1.0.tgz", filepath.Join(packagePath, "test-chart-0.1.0.tgz")))
+
+	fakeGitHub := new(FakeGitHub)
+	r := &Releaser{
+		config: &config.Options{
+			PackagePath:         packagePath,
+			ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}",
+			Policy:              config.Policy{Maintainers: "error", AppVersion: "warning"},
+		},
+		github: fakeGitHub,
+	}
+	err := r.CreateReleases(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "charts violate the metadata policy: test-chart-0.1.0", err.Error())
+	fakeGitHub.AssertNotCalled(t, "CreateRelease", mock.Anything, mock.Anything)
+}
+
+func TestReleaser_CreateReleasesFromManifest(t *testing.T) {
+	packagePath := t.TempDir()
+	require.NoError(t, copyFile("testdata/release-packages/test-chart-0.1.0.tgz", filepath.Join(packagePath, "test-chart-0.1.0.tgz")))
+	// Not in the manifest, so it is not loaded.
+	require.NoError(t, os.WriteFile(filepath.Join(packagePath, "stale-0.0.1.tgz"), []byte("not a package"), 0644))
+	manifest := filepath.Join(packagePath, "manifest.yaml")
+	require.NoError(t, os.WriteFile(manifest, []byte("packages:\n- path: test-chart-0.1.0.tgz\n"), 0644))
+
+	fakeGitHub := &FakeGitHub{releases: []*github.Release{}}
+	fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return()
+	r := &Releaser{
+		config: &config.Options{
+			PackagePath:         "does-not-exist",
+			Manifest:            manifest,
+			ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}",
+		},
+		github: fakeGitHub,
+	}
+	require.NoError(t, r.CreateReleases(context.Background()))
+	fakeGitHub.AssertNumberOfCalls(t, "CreateRelease", 1)
+	assert.Equal(t, filepath.Join(packagePath, "test-chart-0.1.0.tgz"), fakeGitHub.release.Assets[0].Path)
+}
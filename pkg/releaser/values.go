@@ -0,0 +1,328 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// checkValues compares the values and values schema of every chart with the
+// previous released version of the chart, i.e. the greatest released version
+// lower than its own. Breaking changes are written to out, and an error is
+// returned if a chart has breaking changes without a major version bump.
+// Charts whose version was already released are ignored with SkipExisting, as
+// they are not uploaded again.
+func (r *Releaser) checkValues(ctx context.Context, out io.Writer, released map[string]map[string]*releasedPackage, charts []*chart.Chart) error {
+	var failed []string
+	for _, ch := range charts {
+		versions := released[ch.Metadata.Name]
+		if _, ok := versions[ch.Metadata.Version]; ok && r.config.SkipExisting {
+			continue
+		}
+		version, err := semver.StrictNewVersion(ch.Metadata.Version)
+		if err != nil {
+			return errors.Wrapf(err, "invalid version of chart %s", ch.Metadata.Name)
+		}
+		previous, pkg := previousVersion(versions, version)
+		if previous == nil {
+			continue
+		}
+		if isMajorBump(previous, version) {
+			continue
+		}
+		if pkg.AssetID == 0 && pkg.URL == "" {
+			return errors.Errorf("no package URL of chart %s %s", ch.Metadata.Name, previous.Original())
+		}
+
+		previousChart, err := r.downloadChart(ctx, pkg)
+		if err != nil {
+			return errors.Wrapf(err, "error downloading chart %s %s", ch.Metadata.Name, previous.Original())
+		}
+		changes, err := breakingValuesChanges(previousChart, ch)
+		if err != nil {
+			return errors.Wrapf(err, "error comparing values of chart %s", ch.Metadata.Name)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "==> Values of chart %s %s have breaking changes since %s\n", ch.Metadata.Name, ch.Metadata.Version, previous.Original())
+		for _, change := range changes {
+			fmt.Fprintf(out, "[ERROR] %s\n", change)
+		}
+		failed = append(failed, fmt.Sprintf("%s-%s", ch.Metadata.Name, ch.Metadata.Version))
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("breaking values changes need a major version bump: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// previousVersion returns the greatest of the released versions lower than
+// version, and its package. Versions which are not semantic versions are
+// ignored.
+func previousVersion(versions map[string]*releasedPackage, version *semver.Version) (*semver.Version, *releasedPackage) {
+	var previous *semver.Version
+	var previousPackage *releasedPackage
+	for v, pkg := range versions {
+		sv, err := semver.StrictNewVersion(v)
+		if err != nil || !sv.LessThan(version) {
+			continue
+		}
+		if previous == nil || sv.GreaterThan(previous) {
+			previous, previousPackage = sv, pkg
+		}
+	}
+	return previous, previousPackage
+}
+
+// isMajorBump reports whether version increments the major version of
+// previous. Below 1.0.0, incrementing the minor version counts as a major
+// bump, as semantic versioning allows anything to change there.
+func isMajorBump(previous *semver.Version, version *semver.Version) bool {
+	if version.Major() != previous.Major() {
+		return version.Major() > previous.Major()
+	}
+	return version.Major() == 0 && version.Minor() > previous.Minor()
+}
+
+// downloadChart downloads and loads a released chart package. GitHub release
+// assets are downloaded through the GitHub API, which is authenticated, so
+// that packages of private repositories can be downloaded as well.
+func (r *Releaser) downloadChart(ctx context.Context, pkg *releasedPackage) (*chart.Chart, error) {
+	if pkg.AssetID != 0 {
+		rc, err := r.github.DownloadReleaseAsset(ctx, pkg.AssetID)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return loader.LoadArchive(rc)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, pkg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error response: %s", response.Status)
+	}
+	return loader.LoadArchive(response.Body)
+}
+
+// breakingValuesChanges returns the changes of the values and values schema
+// from the previous to the current chart which can break existing
+// deployments.
+func breakingValuesChanges(previous *chart.Chart, current *chart.Chart) ([]string, error) {
+	var changes []string
+	diffValues("", previous.Values, current.Values, &changes)
+
+	// Only changes of a schema are checked. Adding a schema is not
+	// considered breaking, as charts commonly do so in minor versions.
+	if len(previous.Schema) > 0 && len(current.Schema) > 0 {
+		var previousSchema, currentSchema map[string]interface{}
+		if err := json.Unmarshal(previous.Schema, &previousSchema); err != nil {
+			return nil, errors.Wrap(err, "invalid values.schema.json of the previous version")
+		}
+		if err := json.Unmarshal(current.Schema, &currentSchema); err != nil {
+			return nil, errors.Wrap(err, "invalid values.schema.json")
+		}
+		diffSchema("", previousSchema, currentSchema, &changes)
+	}
+	return changes, nil
+}
+
+// diffValues adds the keys of the previous values which were removed or
+// changed between maps and other types to changes. Maps which are empty in
+// the current values are free-form, e.g. 'podAnnotations: {}', so their keys
+// are not compared. Lists are not compared.
+func diffValues(path string, previous map[string]interface{}, current map[string]interface{}, changes *[]string) {
+	for _, key := range sortedKeys(previous) {
+		keyPath := joinPath(path, key)
+		currentValue, ok := current[key]
+		if !ok {
+			*changes = append(*changes, fmt.Sprintf("key %s was removed from values.yaml", keyPath))
+			continue
+		}
+		previousMap, previousIsMap := previous[key].(map[string]interface{})
+		currentMap, currentIsMap := currentValue.(map[string]interface{})
+		switch {
+		case previousIsMap && !currentIsMap && currentValue != nil:
+			*changes = append(*changes, fmt.Sprintf("key %s changed from a map to %s in values.yaml", keyPath, valueKind(currentValue)))
+		case !previousIsMap && currentIsMap && previous[key] != nil:
+			*changes = append(*changes, fmt.Sprintf("key %s changed from %s to a map in values.yaml", keyPath, valueKind(previous[key])))
+		case previousIsMap && currentIsMap && len(currentMap) > 0:
+			diffValues(keyPath, previousMap, currentMap, changes)
+		}
+	}
+}
+
+// diffSchema adds the changes of the previous JSON schema which reject values
+// the previous schema accepted to changes: removed properties of closed
+// objects, newly required properties, narrowed types and enums and closed
+// objects.
+func diffSchema(path string, previous map[string]interface{}, current map[string]interface{}, changes *[]string) {
+	name := path
+	if name == "" {
+		name = "the values"
+	}
+
+	previousTypes, currentTypes := schemaTypes(previous), schemaTypes(current)
+	if len(currentTypes) > 0 {
+		narrowed := len(previousTypes) == 0
+		for _, t := range previousTypes {
+			// Integers are numbers.
+			if !contains(currentTypes, t) && !(t == "integer" && contains(currentTypes, "number")) {
+				narrowed = true
+			}
+		}
+		if narrowed {
+			*changes = append(*changes, fmt.Sprintf("schema type of %s changed from %s to %s", name, formatTypes(previousTypes), formatTypes(currentTypes)))
+		}
+	}
+
+	if currentEnum, ok := current["enum"].([]interface{}); ok {
+		previousEnum, hadEnum := previous["enum"].([]interface{})
+		if !hadEnum || !enumIncludes(currentEnum, previousEnum) {
+			*changes = append(*changes, fmt.Sprintf("schema enum of %s no longer allows all previous values", name))
+		}
+	}
+
+	if isClosed(current) && !isClosed(previous) {
+		*changes = append(*changes, fmt.Sprintf("schema of %s no longer allows additional properties", name))
+	}
+
+	previousRequired, currentRequired := schemaStrings(previous["required"]), schemaStrings(current["required"])
+	for _, property := range currentRequired {
+		if !contains(previousRequired, property) {
+			*changes = append(*changes, fmt.Sprintf("schema property %s is newly required", joinPath(path, property)))
+		}
+	}
+
+	previousProperties, _ := previous["properties"].(map[string]interface{})
+	currentProperties, _ := current["properties"].(map[string]interface{})
+	for _, property := range sortedKeys(previousProperties) {
+		propertyPath := joinPath(path, property)
+		currentProperty, ok := currentProperties[property].(map[string]interface{})
+		if !ok {
+			// Removed properties are only rejected by closed objects.
+			if _, exists := currentProperties[property]; !exists && isClosed(current) {
+				*changes = append(*changes, fmt.Sprintf("schema property %s was removed", propertyPath))
+			}
+			continue
+		}
+		if previousProperty, ok := previousProperties[property].(map[string]interface{}); ok {
+			diffSchema(propertyPath, previousProperty, currentProperty, changes)
+		}
+	}
+
+	previousItems, previousOK := previous["items"].(map[string]interface{})
+	currentItems, currentOK := current["items"].(map[string]interface{})
+	if previousOK && currentOK {
+		diffSchema(path+"[]", previousItems, currentItems, changes)
+	}
+}
+
+// schemaTypes returns the types allowed by the 'type' of a schema.
+func schemaTypes(schema map[string]interface{}) []string {
+	if t, ok := schema["type"].(string); ok {
+		return []string{t}
+	}
+	return schemaStrings(schema["type"])
+}
+
+func schemaStrings(value interface{}) []string {
+	values, _ := value.([]interface{})
+	var strs []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// isClosed reports whether a schema rejects additional properties.
+func isClosed(schema map[string]interface{}) bool {
+	additional, ok := schema["additionalProperties"].(bool)
+	return ok && !additional
+}
+
+func enumIncludes(enum []interface{}, values []interface{}) bool {
+	for _, value := range values {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTypes(types []string) string {
+	if len(types) == 0 {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
+
+func valueKind(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "a list"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
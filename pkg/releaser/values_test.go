@@ -0,0 +1,284 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/github"
+)
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		expected []string
+	}{
+		{
+			name:     "added-keys",
+			previous: "image:\n  tag: 1.0\n",
+			current:  "image:\n  tag: 1.1\n  pullPolicy: Always\nreplicas: 2\n",
+		},
+		{
+			name:     "removed-keys",
+			previous: "image:\n  tag: 1.0\n  repository: app\nlegacy: true\n",
+			current:  "image:\n  tag: 1.0\n",
+			expected: []string{
+				"key image.repository was removed from values.yaml",
+				"key legacy was removed from values.yaml",
+			},
+		},
+		{
+			name:     "renamed-key",
+			previous: "service:\n  port: 80\n",
+			current:  "service:\n  httpPort: 80\n",
+			expected: []string{"key service.port was removed from values.yaml"},
+		},
+		{
+			name:     "changed-structure",
+			previous: "resources:\n  limits:\n    cpu: 1\nports: [80]\n",
+			current:  "resources: small\nports:\n  http: 80\n",
+			expected: []string{
+				"key ports changed from a list to a map in values.yaml",
+				"key resources changed from a map to a string in values.yaml",
+			},
+		},
+		{
+			name:     "free-form-map",
+			previous: "podAnnotations:\n  example.com/scrape: \"true\"\n",
+			current:  "podAnnotations: {}\n",
+		},
+		{
+			name:     "null-and-scalar-changes",
+			previous: "nodeSelector: null\nreplicas: 1\n",
+			current:  "nodeSelector:\n  os: linux\nreplicas: \"1\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var previous, current map[string]interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.previous), &previous))
+			require.NoError(t, yaml.Unmarshal([]byte(tt.current), &current))
+			var changes []string
+			diffValues("", previous, current, &changes)
+			assert.Equal(t, tt.expected, changes)
+		})
+	}
+}
+
+func TestDiffSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		expected []string
+	}{
+		{
+			name:     "loosened",
+			previous: `{"type": "object", "required": ["image"], "additionalProperties": false, "properties": {"image": {"type": "string", "enum": ["a", "b"]}, "port": {"type": "integer"}}}`,
+			current:  `{"type": "object", "properties": {"image": {"type": ["string", "null"]}, "port": {"type": "number"}, "extra": {"type": "string"}}}`,
+		},
+		{
+			name:     "newly-required",
+			previous: `{"properties": {"image": {"type": "object", "properties": {"tag": {"type": "string"}}}}}`,
+			current:  `{"required": ["image"], "properties": {"image": {"type": "object", "required": ["tag"], "properties": {"tag": {"type": "string"}}}}}`,
+			expected: []string{
+				"schema property image is newly required",
+				"schema property image.tag is newly required",
+			},
+		},
+		{
+			name:     "narrowed-types-and-enums",
+			previous: `{"properties": {"port": {"type": ["integer", "string"]}, "mode": {"enum": ["a", "b"]}, "level": {}, "hosts": {"type": "array", "items": {"type": "string"}}}}`,
+			current:  `{"properties": {"port": {"type": "integer"}, "mode": {"enum": ["a"]}, "level": {"enum": ["debug"]}, "hosts": {"type": "array", "items": {"type": "object"}}}}`,
+			expected: []string{
+				"schema type of hosts[] changed from string to object",
+				"schema enum of level no longer allows all previous values",
+				"schema enum of mode no longer allows all previous values",
+				"schema type of port changed from integer|string to integer",
+			},
+		},
+		{
+			name:     "closed-object",
+			previous: `{"properties": {"image": {"type": "string"}, "legacy": {"type": "boolean"}}}`,
+			current:  `{"additionalProperties": false, "properties": {"image": {"type": "string"}}}`,
+			expected: []string{
+				"schema of the values no longer allows additional properties",
+				"schema property legacy was removed",
+			},
+		},
+		{
+			name:     "removed-property-of-open-object",
+			previous: `{"properties": {"legacy": {"type": "boolean"}}}`,
+			current:  `{"properties": {}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var previous, current map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.previous), &previous))
+			require.NoError(t, json.Unmarshal([]byte(tt.current), &current))
+			var changes []string
+			diffSchema("", previous, current, &changes)
+			assert.Equal(t, tt.expected, changes)
+		})
+	}
+}
+
+func TestIsMajorBump(t *testing.T) {
+	tests := []struct {
+		previous string
+		version  string
+		expected bool
+	}{
+		{"1.2.3", "2.0.0", true},
+		{"1.2.3", "1.3.0", false},
+		{"1.2.3", "1.2.4", false},
+		{"2.0.0-rc.1", "2.0.0", false},
+		{"0.1.0", "0.2.0", true},
+		{"0.1.0", "0.1.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.previous+"-"+tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isMajorBump(semver.MustParse(tt.previous), semver.MustParse(tt.version)))
+		})
+	}
+}
+
+// saveChart writes a package of the chart app in the given version, with the
+// given values and schema, to dir and returns its path.
+func saveChart(t *testing.T, dir string, version string, values string, schema string) string {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "app", Version: version},
+		Raw:      []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte(values)}},
+	}
+	if schema != "" {
+		ch.Schema = []byte(schema)
+	}
+	path, err := chartutil.Save(ch, dir)
+	require.NoError(t, err)
+	return path
+}
+
+func TestReleaser_CreateReleasesCheckValues(t *testing.T) {
+	serverDir := t.TempDir()
+	previous := saveChart(t, serverDir, "1.1.0", "image:\n  tag: 1.0\n  repository: app\n", `{"properties": {"image": {"type": "object"}}}`)
+	oldest := saveChart(t, serverDir, "0.9.0", "removedLongAgo: true\n", "")
+	server := httptest.NewServer(http.FileServer(http.Dir(serverDir)))
+	defer server.Close()
+	index, err := repo.IndexDirectory(serverDir, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(serverDir, "index.yaml"), 0644))
+
+	// Release assets are downloaded through the GitHub client, as their
+	// browser download URLs need no authentication only in public
+	// repositories.
+	releases := []*github.Release{
+		{Assets: []*github.Asset{{URL: "https://github.com/owner/repo/releases/download/app-1.1.0/app-1.1.0.tgz", ID: 11}}},
+		{Assets: []*github.Asset{{URL: "https://github.com/owner/repo/releases/download/app-0.9.0/app-0.9.0.tgz", ID: 9}}},
+	}
+	assetFiles := map[int64]string{11: previous, 9: oldest}
+
+	tests := []struct {
+		name      string
+		version   string
+		values    string
+		schema    string
+		fromIndex bool
+		error     string
+	}{
+		{
+			name:    "compatible",
+			version: "1.2.0",
+			values:  "image:\n  tag: 1.1\n  repository: app\n  pullPolicy: Always\n",
+			schema:  `{"properties": {"image": {"type": "object"}}}`,
+		},
+		{
+			name:    "removed-key",
+			version: "1.2.0",
+			values:  "image:\n  tag: 1.1\n",
+			error:   "breaking values changes need a major version bump: app-1.2.0",
+		},
+		{
+			name:    "narrowed-schema",
+			version: "1.1.1",
+			values:  "image:\n  tag: 1.1\n  repository: app\n",
+			schema:  `{"properties": {"image": {"type": "string"}}}`,
+			error:   "breaking values changes need a major version bump: app-1.1.1",
+		},
+		{
+			name:    "major-bump",
+			version: "2.0.0",
+			values:  "image:\n  tag: 1.1\n",
+		},
+		{
+			name:      "compatible-from-index",
+			version:   "1.2.0",
+			values:    "image:\n  tag: 1.1\n  repository: app\n",
+			fromIndex: true,
+		},
+		{
+			name:      "removed-key-from-index",
+			version:   "1.2.0",
+			values:    "image:\n  tag: 1.1\n",
+			fromIndex: true,
+			error:     "breaking values changes need a major version bump: app-1.2.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := t.TempDir()
+			saveChart(t, packagePath, tt.version, tt.values, tt.schema)
+
+			fakeGitHub := &FakeGitHub{releases: releases, assetFiles: assetFiles}
+			options := &config.Options{
+				PackagePath:         packagePath,
+				ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}",
+				CheckValues:         true,
+			}
+			if tt.fromIndex {
+				fakeGitHub.releases = []*github.Release{}
+				options.IndexURL = server.URL + "/index.yaml"
+			}
+			fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return()
+			r := &Releaser{config: options, github: fakeGitHub}
+			err := r.CreateReleases(context.Background())
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.error, err.Error())
+				fakeGitHub.AssertNotCalled(t, "CreateRelease", mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(packagePath, "app-"+tt.version+".tgz"), fakeGitHub.release.Assets[0].Path)
+		})
+	}
+}
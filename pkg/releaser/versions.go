@@ -0,0 +1,221 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+// releasedPackage is where the package of a released chart version can be
+// downloaded from: a GitHub release asset, or a package URL from the index.
+type releasedPackage struct {
+	AssetID int64
+	URL     string
+}
+
+// releasedVersions returns the packages of the versions of the named charts
+// which were released before, by chart name and version. They are taken from
+// the chart packages attached to GitHub releases and from the index at the
+// configured index URL.
+func (r *Releaser) releasedVersions(ctx context.Context, names []string) (map[string]map[string]*releasedPackage, error) {
+	versions := map[string]map[string]*releasedPackage{}
+	add := func(name string, version string, pkg *releasedPackage) {
+		if versions[name] == nil {
+			versions[name] = map[string]*releasedPackage{}
+		}
+		if _, ok := versions[name][version]; !ok {
+			versions[name][version] = pkg
+		}
+	}
+
+	releases, err := r.github.GetReleases(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing GitHub releases")
+	}
+	for _, release := range releases {
+		for _, asset := range release.Assets {
+			assetURL, err := url.Parse(asset.URL)
+			if err != nil {
+				continue
+			}
+			fileName := path.Base(assetURL.Path)
+			if path.Ext(fileName) != chartAssetFileExtension {
+				continue
+			}
+			baseName := strings.TrimSuffix(fileName, chartAssetFileExtension)
+			// Chart names may contain dashes, so the version is what
+			// follows the name of a chart being released.
+			for _, name := range names {
+				if strings.HasPrefix(baseName, name+"-") {
+					add(name, strings.TrimPrefix(baseName, name+"-"), &releasedPackage{AssetID: asset.ID, URL: asset.URL})
+				}
+			}
+		}
+	}
+
+	if r.config.IndexURL != "" {
+		indexURL, err := url.Parse(r.config.IndexURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid index URL %s", r.config.IndexURL)
+		}
+		indexFile, err := fetchIndexFile(ctx, r.config.IndexURL)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			for _, entry := range indexFile.Entries[name] {
+				packageURL := ""
+				if len(entry.URLs) > 0 {
+					// Package URLs may be relative to the index.
+					if ref, err := url.Parse(entry.URLs[0]); err == nil {
+						packageURL = indexURL.ResolveReference(ref).String()
+					}
+				}
+				add(name, entry.Version, &releasedPackage{URL: packageURL})
+			}
+		}
+	}
+	return versions, nil
+}
+
+// fetchIndexFile downloads and parses the chart repository index at the
+// given URL.
+func fetchIndexFile(ctx context.Context, indexURL string) (*repo.IndexFile, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid index URL %s", indexURL)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading index %s", indexURL)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error downloading index %s: %s", indexURL, response.Status)
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error downloading index %s", indexURL)
+	}
+
+	indexFile := &repo.IndexFile{}
+	if err := yaml.Unmarshal(data, indexFile); err != nil {
+		return nil, errors.Wrapf(err, "error parsing index %s", indexURL)
+	}
+	return indexFile, nil
+}
+
+// checkVersions checks that the chart versions being released are strict
+// semantic versions which were not released before and are greater than the
+// latest released version of the chart. With allowBackport, they need only be
+// greater than the latest released version with the same major and minor
+// version. The violations are written to out. Versions which were released
+// before are ignored with skipExisting, as they are not uploaded again.
+func checkVersions(out io.Writer, released map[string]map[string]*releasedPackage, charts []*chart.Metadata, allowBackport bool, skipExisting bool) error {
+	// Versions of a chart in the same upload are checked in ascending order,
+	// each against the ones before.
+	sorted := make([]*chart.Metadata, len(charts))
+	copy(sorted, charts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		vi, erri := semver.StrictNewVersion(sorted[i].Version)
+		vj, errj := semver.StrictNewVersion(sorted[j].Version)
+		return erri == nil && errj == nil && vi.LessThan(vj)
+	})
+
+	existing := map[string][]string{}
+	for name, versions := range released {
+		for version := range versions {
+			existing[name] = append(existing[name], version)
+		}
+	}
+
+	var failed []string
+	for _, metadata := range sorted {
+		message := versionViolation(existing[metadata.Name], metadata.Version, allowBackport)
+		_, isReleased := released[metadata.Name][metadata.Version]
+		if message == "" || skipExisting && isReleased {
+			existing[metadata.Name] = append(existing[metadata.Name], metadata.Version)
+			continue
+		}
+		fmt.Fprintf(out, "[ERROR] %s %s: %s\n", metadata.Name, metadata.Version, message)
+		failed = append(failed, fmt.Sprintf("%s-%s", metadata.Name, metadata.Version))
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("chart versions cannot be released: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// versionViolation returns why the version cannot be released after the
+// existing versions, or "" if it can.
+func versionViolation(existing []string, version string, allowBackport bool) string {
+	v, err := semver.StrictNewVersion(version)
+	if err != nil {
+		return "version is not a valid semantic version"
+	}
+
+	var latest, latestOnLine *semver.Version
+	for _, e := range existing {
+		ev, err := semver.StrictNewVersion(e)
+		if err != nil {
+			continue
+		}
+		// Versions differing only in build metadata have the same
+		// precedence, so they count as the same version.
+		if ev.Equal(v) {
+			return fmt.Sprintf("version was already released as %s", e)
+		}
+		if latest == nil || ev.GreaterThan(latest) {
+			latest = ev
+		}
+		if ev.Major() == v.Major() && ev.Minor() == v.Minor() && (latestOnLine == nil || ev.GreaterThan(latestOnLine)) {
+			latestOnLine = ev
+		}
+	}
+
+	switch {
+	case latestOnLine != nil && !v.GreaterThan(latestOnLine):
+		return fmt.Sprintf("version is not greater than the latest version %s of %d.%d.x", latestOnLine, v.Major(), v.Minor())
+	case !allowBackport && latest != nil && !v.GreaterThan(latest):
+		return fmt.Sprintf("version is not greater than the latest version %s, use --allow-backport to release older lines", latest)
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/github"
+)
+
+func TestVersionViolation(t *testing.T) {
+	existing := []string{"1.2.3", "1.3.0", "1.3.1", "2.0.0-rc.1", "0.1"}
+	tests := []struct {
+		name          string
+		version       string
+		allowBackport bool
+		expected      string
+	}{
+		{
+			name:    "greater",
+			version: "2.0.0",
+		},
+		{
+			name:    "prerelease-greater",
+			version: "2.0.0-rc.2",
+		},
+		{
+			name:     "lower",
+			version:  "1.3.2",
+			expected: "version is not greater than the latest version 2.0.0-rc.1, use --allow-backport to release older lines",
+		},
+		{
+			name:          "backport",
+			version:       "1.2.4",
+			allowBackport: true,
+		},
+		{
+			name:          "backport-new-line",
+			version:       "1.1.0",
+			allowBackport: true,
+		},
+		{
+			name:     "build-metadata",
+			version:  "1.3.0+hotfix",
+			expected: "version was already released as 1.3.0",
+		},
+		{
+			name:          "backport-lower-on-line",
+			version:       "1.2.2",
+			allowBackport: true,
+			expected:      "version is not greater than the latest version 1.2.3 of 1.2.x",
+		},
+		{
+			name:     "reused",
+			version:  "1.3.1",
+			expected: "version was already released as 1.3.1",
+		},
+		{
+			name:     "non-semver",
+			version:  "0.1",
+			expected: "version is not a valid semantic version",
+		},
+		{
+			name:     "v-prefix",
+			version:  "v2.1.0",
+			expected: "version is not a valid semantic version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, versionViolation(existing, tt.version, tt.allowBackport))
+		})
+	}
+}
+
+func TestCheckVersions(t *testing.T) {
+	released := map[string]map[string]*releasedPackage{"app": {"1.0.0": {}}, "db": {"0.5.0": {}}}
+	charts := []*chart.Metadata{
+		{Name: "app", Version: "1.10.0"},
+		{Name: "app", Version: "1.9.0"},
+		{Name: "db", Version: "0.5.0"},
+		{Name: "web", Version: "1.0"},
+	}
+
+	var out bytes.Buffer
+	err := checkVersions(&out, released, charts, false, false)
+	require.Error(t, err)
+	assert.Equal(t, "chart versions cannot be released: db-0.5.0, web-1.0", err.Error())
+	assert.Equal(t, "[ERROR] db 0.5.0: version was already released as 0.5.0\n"+
+		"[ERROR] web 1.0: version is not a valid semantic version\n",
+		out.String())
+
+	out.Reset()
+	require.NoError(t, checkVersions(&out, released, charts[:3], false, true))
+
+	out.Reset()
+	err = checkVersions(&out, released, []*chart.Metadata{{Name: "app", Version: "1.9.0"}, {Name: "app", Version: "1.9.0"}}, false, false)
+	require.Error(t, err)
+	assert.Equal(t, "[ERROR] app 1.9.0: version was already released as 1.9.0\n", out.String())
+}
+
+func TestReleaser_CreateReleasesVersions(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+  test-chart:
+  - name: test-chart
+    version: 0.2.0
+`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(index))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		releases      []*github.Release
+		indexURL      string
+		allowBackport bool
+		error         string
+	}{
+		{
+			name:     "first-release",
+			releases: []*github.Release{},
+		},
+		{
+			name: "released-on-github",
+			releases: []*github.Release{
+				{Assets: []*github.Asset{{URL: "https://github.com/owner/repo/releases/download/test-chart-0.1.0/test-chart-0.1.0.tgz"}}},
+			},
+			error: "chart versions cannot be released: test-chart-0.1.0",
+		},
+		{
+			name: "other-chart-with-common-prefix",
+			releases: []*github.Release{
+				{Assets: []*github.Asset{{URL: "https://github.com/owner/repo/releases/download/test-chart-extra-1.0.0/test-chart-extra-1.0.0.tgz"}}},
+			},
+		},
+		{
+			name:     "greater-version-in-index",
+			releases: []*github.Release{},
+			indexURL: server.URL + "/index.yaml",
+			error:    "chart versions cannot be released: test-chart-0.1.0",
+		},
+		{
+			name:          "backport",
+			releases:      []*github.Release{},
+			indexURL:      server.URL + "/index.yaml",
+			allowBackport: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := t.TempDir()
+			require.NoError(t, copyFile("testdata/release-packages/test-chart-0.1.0.tgz", filepath.Join(packagePath, "test-chart-0.1.0.tgz")))
+
+			fakeGitHub := &FakeGitHub{releases: tt.releases}
+			fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return()
+			r := &Releaser{
+				config: &config.Options{
+					PackagePath:         packagePath,
+					ReleaseNameTemplate: "{{ .Name }}-{{ .Version }}",
+					IndexURL:            tt.indexURL,
+					AllowBackport:       tt.allowBackport,
+				},
+				github: fakeGitHub,
+			}
+			err := r.CreateReleases(context.Background())
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.error, err.Error())
+				fakeGitHub.AssertNotCalled(t, "CreateRelease", mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			fakeGitHub.AssertNumberOfCalls(t, "CreateRelease", 1)
+		})
+	}
+}
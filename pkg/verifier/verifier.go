@@ -0,0 +1,274 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/packager"
+)
+
+// Result is the outcome of verifying a chart package.
+type Result struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Package is the path or URL of the chart package.
+	Package  string   `json:"package"`
+	Verified bool     `json:"verified"`
+	SignedBy []string `json:"signedBy,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Verifier verifies chart packages against their provenance files.
+type Verifier struct {
+	config    *config.Options
+	signatory *provenance.Signatory
+}
+
+// NewVerifier returns a Verifier checking signatures against the keyring
+// configured with KeyRing.
+func NewVerifier(config *config.Options) (*Verifier, error) {
+	path, err := homedir.Expand(config.KeyRing)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error expanding keyring path %s", config.KeyRing)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading keyring")
+	}
+	ring, err := packager.ReadKeyRing(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{
+		config:    config,
+		signatory: &provenance.Signatory{KeyRing: ring},
+	}, nil
+}
+
+// VerifyPackages verifies the chart packages in the given paths, or all
+// packages in the package path if there are none. The provenance file of a
+// package is expected next to it.
+func (v *Verifier) VerifyPackages(paths []string) ([]*Result, error) {
+	if len(paths) == 0 {
+		var err error
+		if paths, err = filepath.Glob(filepath.Join(v.config.PackagePath, "*.tgz")); err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, errors.Errorf("no chart packages found at %s", v.config.PackagePath)
+		}
+	}
+
+	var results []*Result
+	for _, path := range paths {
+		result := &Result{Package: path}
+		v.verify(result, path)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// VerifyIndex verifies every version of the charts with the given names, or of
+// all charts, in the index at IndexURL. Packages and provenance files are
+// downloaded to a temporary directory, and packages are also checked against
+// the digest in the index.
+func (v *Verifier) VerifyIndex(ctx context.Context, names []string) ([]*Result, error) {
+	dir, err := os.MkdirTemp("", "cr-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	indexPath := filepath.Join(dir, "index.yaml")
+	if err := download(ctx, v.config.IndexURL, indexPath); err != nil {
+		return nil, errors.Wrapf(err, "error downloading index %s", v.config.IndexURL)
+	}
+	index, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading index %s", v.config.IndexURL)
+	}
+
+	if len(names) == 0 {
+		for name := range index.Entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if _, ok := index.Entries[name]; !ok {
+			return nil, errors.Errorf("chart %q not found in index %s", name, v.config.IndexURL)
+		}
+	}
+
+	var results []*Result
+	for _, name := range names {
+		for i, entry := range index.Entries[name] {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			result := &Result{Name: entry.Name, Version: entry.Version}
+			results = append(results, result)
+			if len(entry.URLs) == 0 {
+				result.Error = "no package URL in index"
+				continue
+			}
+			chartURL, err := resolveURL(v.config.IndexURL, entry.URLs[0])
+			if err != nil {
+				result.Package = entry.URLs[0]
+				result.Error = err.Error()
+				continue
+			}
+			result.Package = chartURL
+
+			// Keep the file name, it is part of the signed data.
+			chartPath := filepath.Join(dir, name, strconv.Itoa(i), filepath.Base(chartURL))
+			if err := os.MkdirAll(filepath.Dir(chartPath), 0755); err != nil {
+				return nil, err
+			}
+			if err := download(ctx, chartURL, chartPath); err != nil {
+				result.Error = errors.Wrap(err, "error downloading package").Error()
+				continue
+			}
+			if err := download(ctx, chartURL+".prov", chartPath+".prov"); err != nil {
+				result.Error = errors.Wrap(err, "error downloading provenance file").Error()
+				continue
+			}
+			if entry.Digest != "" {
+				digest, err := provenance.DigestFile(chartPath)
+				if err != nil {
+					return nil, err
+				}
+				if digest != entry.Digest {
+					result.Error = fmt.Sprintf("package digest %s does not match digest %s in index", digest, entry.Digest)
+					continue
+				}
+			}
+			v.verify(result, chartPath)
+		}
+	}
+	return results, nil
+}
+
+// verify checks the chart package in the given path against its provenance
+// file and records the outcome in result.
+func (v *Verifier) verify(result *Result, path string) {
+	ch, err := loader.LoadFile(path)
+	if err != nil {
+		result.Error = errors.Wrapf(err, "%s is not a helm chart package", path).Error()
+		return
+	}
+	if result.Name == "" {
+		result.Name = ch.Metadata.Name
+		result.Version = ch.Metadata.Version
+	}
+
+	if _, err := os.Stat(path + ".prov"); err != nil {
+		result.Error = "no provenance file"
+		return
+	}
+	verification, err := v.signatory.Verify(path, path+".prov")
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	for name := range verification.SignedBy.Identities {
+		result.SignedBy = append(result.SignedBy, name)
+	}
+	sort.Strings(result.SignedBy)
+	result.Verified = true
+}
+
+// resolveURL resolves a possibly relative package URL from an index against
+// the URL of the index.
+func resolveURL(indexURL string, ref string) (string, error) {
+	base, err := url.Parse(indexURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid index URL %s", indexURL)
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid package URL %s", ref)
+	}
+	return base.ResolveReference(refURL).String(), nil
+}
+
+// download writes the content at the given URL to the file in path.
+func download(ctx context.Context, rawURL string, path string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("error response: %s", response.Status)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, response.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Failed returns the number of results which did not verify.
+func Failed(results []*Result) int {
+	failed := 0
+	for _, result := range results {
+		if !result.Verified {
+			failed++
+		}
+	}
+	return failed
+}
+
+// PrintReport writes one line per result to out.
+func PrintReport(out io.Writer, results []*Result) {
+	for _, result := range results {
+		chart := strings.TrimSpace(result.Name + " " + result.Version)
+		if chart == "" {
+			chart = result.Package
+		}
+		if result.Verified {
+			fmt.Fprintf(out, "PASS %s (%s), signed by %s\n", chart, result.Package, strings.Join(result.SignedBy, ", "))
+		} else {
+			fmt.Fprintf(out, "FAIL %s (%s): %s\n", chart, result.Package, result.Error)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/tklauenberg/chart-releaser/pkg/config"
+	"github.com/tklauenberg/chart-releaser/pkg/packager"
+)
+
+const (
+	testKeyRing = "../packager/testdata/testkeyring.gpg"
+	testKeyName = "Chart Releaser Test Key <no-reply@example.com>"
+)
+
+// signedPackage packages and signs the test chart into dir and returns the
+// path of the package.
+func signedPackage(t *testing.T, dir string) string {
+	p := packager.NewPackager(&config.Options{
+		PackagePath:    dir,
+		Sign:           true,
+		KeyRing:        testKeyRing,
+		Key:            "Chart Releaser",
+		PassphraseFile: "../packager/testdata/passphrase-file.txt",
+	}, []string{"../packager/testdata/test-chart"}, nil)
+	require.NoError(t, p.CreatePackages(context.Background()))
+	return filepath.Join(dir, "test-chart-0.1.0.tgz")
+}
+
+// tamper appends data to the package in the given path, so that it no longer
+// matches its provenance file.
+func tamper(t *testing.T, path string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.Write(make([]byte, 512))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestVerifier_VerifyPackages(t *testing.T) {
+	tests := []struct {
+		name     string
+		prepare  func(t *testing.T, path string)
+		paths    func(path string) []string
+		verified bool
+		error    string
+	}{
+		{
+			name:     "valid-signature",
+			verified: true,
+		},
+		{
+			name:     "package-path",
+			paths:    func(path string) []string { return nil },
+			verified: true,
+		},
+		{
+			name:    "tampered-package",
+			prepare: tamper,
+			error:   "sha256 sum does not match",
+		},
+		{
+			name:    "missing-provenance-file",
+			prepare: func(t *testing.T, path string) { require.NoError(t, os.Remove(path+".prov")) },
+			error:   "no provenance file",
+		},
+		{
+			name: "not-a-chart",
+			paths: func(path string) []string {
+				return []string{filepath.Join(filepath.Dir(path), "test-chart-0.1.0.tgz.prov")}
+			},
+			error: "is not a helm chart package",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := signedPackage(t, dir)
+			if tt.prepare != nil {
+				tt.prepare(t, path)
+			}
+			paths := []string{path}
+			if tt.paths != nil {
+				paths = tt.paths(path)
+			}
+
+			v, err := NewVerifier(&config.Options{KeyRing: testKeyRing, PackagePath: dir})
+			require.NoError(t, err)
+			results, err := v.VerifyPackages(paths)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			result := results[0]
+			assert.Equal(t, tt.verified, result.Verified)
+			if tt.verified {
+				assert.Equal(t, "test-chart", result.Name)
+				assert.Equal(t, "0.1.0", result.Version)
+				assert.Equal(t, path, result.Package)
+				assert.Equal(t, []string{testKeyName}, result.SignedBy)
+				assert.Empty(t, result.Error)
+				assert.Equal(t, 0, Failed(results))
+			} else {
+				assert.Contains(t, result.Error, tt.error)
+				assert.Equal(t, 1, Failed(results))
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyPackagesNoPackages(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVerifier(&config.Options{KeyRing: testKeyRing, PackagePath: dir})
+	require.NoError(t, err)
+	_, err = v.VerifyPackages(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no chart packages found at "+dir)
+}
+
+func TestNewVerifier(t *testing.T) {
+	_, err := NewVerifier(&config.Options{KeyRing: "testdata/missing.gpg"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading keyring")
+}
+
+func TestVerifier_VerifyIndex(t *testing.T) {
+	// The server has a signed package, a tampered one, one without a
+	// provenance file, and one which does not match the index.
+	dir := t.TempDir()
+	var packages []string
+	for _, name := range []string{"signed", "tampered", "unsigned", "replaced"} {
+		packages = append(packages, signedPackage(t, filepath.Join(dir, name)))
+	}
+	tamper(t, packages[1])
+	require.NoError(t, os.Remove(packages[2]+".prov"))
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	index := repo.NewIndexFile()
+	for i, name := range []string{"signed", "tampered", "unsigned", "replaced"} {
+		ch, err := loader.LoadFile(packages[i])
+		require.NoError(t, err)
+		ch.Metadata.Name = name
+		digest, err := provenance.DigestFile(packages[i])
+		require.NoError(t, err)
+		require.NoError(t, index.MustAdd(ch.Metadata, "test-chart-0.1.0.tgz", server.URL+"/"+name, digest))
+	}
+	tamper(t, packages[3])
+	// Relative URLs are resolved against the index URL.
+	index.Entries["signed"][0].URLs = []string{"signed/test-chart-0.1.0.tgz"}
+	require.NoError(t, index.WriteFile(filepath.Join(dir, "index.yaml"), 0644))
+
+	tests := []struct {
+		name     string
+		charts   []string
+		expected map[string]string
+		error    string
+	}{
+		{
+			name:   "all-charts",
+			charts: nil,
+			expected: map[string]string{
+				"replaced": "does not match digest",
+				"signed":   "",
+				"tampered": "sha256 sum does not match",
+				"unsigned": "error downloading provenance file: error response: 404 Not Found",
+			},
+		},
+		{
+			name:     "selected-charts",
+			charts:   []string{"signed"},
+			expected: map[string]string{"signed": ""},
+		},
+		{
+			name:   "unknown-chart",
+			charts: []string{"missing"},
+			error:  `chart "missing" not found in index`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier(&config.Options{KeyRing: testKeyRing, IndexURL: server.URL + "/index.yaml"})
+			require.NoError(t, err)
+			results, err := v.VerifyIndex(context.Background(), tt.charts)
+			if tt.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.error)
+				return
+			}
+			require.NoError(t, err)
+
+			actual := map[string]string{}
+			for _, result := range results {
+				assert.Equal(t, result.Error == "", result.Verified, result.Name)
+				actual[result.Name] = result.Error
+				if expected, ok := tt.expected[result.Name]; ok && expected != "" {
+					assert.Contains(t, result.Error, expected)
+					actual[result.Name] = expected
+				}
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var out bytes.Buffer
+	PrintReport(&out, []*Result{
+		{Name: "alpha", Version: "1.0.0", Package: "alpha-1.0.0.tgz", Verified: true, SignedBy: []string{testKeyName}},
+		{Name: "beta", Version: "2.0.0", Package: "beta-2.0.0.tgz", Error: "no provenance file"},
+		{Package: "broken.tgz", Error: "broken.tgz is not a helm chart package"},
+	})
+	assert.Equal(t, `PASS alpha 1.0.0 (alpha-1.0.0.tgz), signed by `+testKeyName+`
+FAIL beta 2.0.0 (beta-2.0.0.tgz): no provenance file
+FAIL broken.tgz (broken.tgz): broken.tgz is not a helm chart package
+`, out.String())
+}